@@ -0,0 +1,83 @@
+// Package aoc contains the common interface implemented by the solutions of each day, and the code to run them.
+package aoc
+
+import (
+	"compress/gzip"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Solver is implemented by the solution of every day.
+type Solver interface {
+	// Parse reads the puzzle input
+	Parse(r io.Reader) error
+	// Part1 returns the answer to the first part of the puzzle
+	Part1() any
+	// Part2 returns the answer to the second part of the puzzle. It is always called after Part1.
+	Part2() any
+}
+
+// Flagger can optionally be implemented by a Solver that takes extra command line options.
+type Flagger interface {
+	Flags(fs *flag.FlagSet)
+}
+
+// Result holds the answers of a single run, and the time each step took.
+// When encoded as JSON, durations are in nanoseconds.
+type Result struct {
+	Part1     any           `json:"part1"`
+	Part2     any           `json:"part2"`
+	ParseTime time.Duration `json:"parse_ns"`
+	Part1Time time.Duration `json:"part1_ns"`
+	Part2Time time.Duration `json:"part2_ns"`
+}
+
+// ParseFile lets the solver parse the given input file. Use "-" to read from stdin, files ending in ".gz" are decompressed.
+// A ParseError returned by the solver gets the file name filled in.
+func ParseFile(s Solver, filename string) error {
+	var r io.Reader = os.Stdin
+	if filename != "-" {
+		f, err := os.Open(filename)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	if strings.HasSuffix(filename, ".gz") {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	err := s.Parse(r)
+	var perr *ParseError
+	if errors.As(err, &perr) && perr.File == "" {
+		perr.File = filename
+	}
+	return err
+}
+
+// Run parses the input file and solves both parts, timing every step.
+func Run(s Solver, filename string) (Result, error) {
+	var res Result
+	starttime := time.Now()
+	if err := ParseFile(s, filename); err != nil {
+		return res, err
+	}
+	parsetime := time.Now()
+	res.Part1 = s.Part1()
+	part1time := time.Now()
+	res.Part2 = s.Part2()
+	part2time := time.Now()
+	res.ParseTime = parsetime.Sub(starttime)
+	res.Part1Time = part1time.Sub(parsetime)
+	res.Part2Time = part2time.Sub(part1time)
+	return res, nil
+}
@@ -0,0 +1,68 @@
+// Package aoctest contains helpers to test and benchmark the solutions of each day.
+package aoctest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jpcornet/AoC2022/aoc"
+)
+
+// Quiet discards everything the solvers print on stdout, until the test or benchmark is done.
+func Quiet(tb testing.TB) {
+	devnull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = devnull
+	tb.Cleanup(func() {
+		os.Stdout = stdout
+		devnull.Close()
+	})
+}
+
+// BenchInput returns the real puzzle input, input.txt in the given directory, if it is there. Or the sample input otherwise.
+func BenchInput(dir string) string {
+	if _, err := os.Stat(filepath.Join(dir, "input.txt")); err == nil {
+		return filepath.Join(dir, "input.txt")
+	}
+	return filepath.Join(dir, "sample.txt")
+}
+
+func parse(tb testing.TB, newSolver func() aoc.Solver, filename string) aoc.Solver {
+	s := newSolver()
+	if err := aoc.ParseFile(s, filename); err != nil {
+		tb.Fatal(err)
+	}
+	return s
+}
+
+// Benchmark runs separate benchmarks for parsing the input file, part 1 and part 2.
+// Every iteration uses a freshly parsed solver, only the step itself is timed.
+func Benchmark(b *testing.B, newSolver func() aoc.Solver, filename string) {
+	Quiet(b)
+	b.Run("parse", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			parse(b, newSolver, filename)
+		}
+	})
+	b.Run("part1", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			s := parse(b, newSolver, filename)
+			b.StartTimer()
+			s.Part1()
+		}
+	})
+	b.Run("part2", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			s := parse(b, newSolver, filename)
+			s.Part1()
+			b.StartTimer()
+			s.Part2()
+		}
+	})
+}
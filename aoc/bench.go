@@ -0,0 +1,50 @@
+package aoc
+
+import (
+	"sort"
+	"time"
+)
+
+// Stats summarizes how long a step took over several runs.
+type Stats struct {
+	Min, Median, P95 time.Duration
+}
+
+// BenchResult holds the statistics of every step of a solver.
+type BenchResult struct {
+	Runs                int
+	Parse, Part1, Part2 Stats
+}
+
+func make_stats(d []time.Duration) Stats {
+	sort.Slice(d, func(i, j int) bool { return d[i] < d[j] })
+	median := d[len(d)/2]
+	if len(d)%2 == 0 {
+		median = (d[len(d)/2-1] + d[len(d)/2]) / 2
+	}
+	// the smallest duration that is at least as long as 95% of the runs
+	p95 := d[(len(d)*95+99)/100-1]
+	return Stats{Min: d[0], Median: median, P95: p95}
+}
+
+// Bench runs a fresh solver on the input file n times, and collects the statistics of each step.
+func Bench(newSolver func() Solver, filename string, n int) (BenchResult, error) {
+	parse := make([]time.Duration, 0, n)
+	part1 := make([]time.Duration, 0, n)
+	part2 := make([]time.Duration, 0, n)
+	for i := 0; i < n; i++ {
+		res, err := Run(newSolver(), filename)
+		if err != nil {
+			return BenchResult{}, err
+		}
+		parse = append(parse, res.ParseTime)
+		part1 = append(part1, res.Part1Time)
+		part2 = append(part2, res.Part2Time)
+	}
+	return BenchResult{
+		Runs:  n,
+		Parse: make_stats(parse),
+		Part1: make_stats(part1),
+		Part2: make_stats(part2),
+	}, nil
+}
@@ -0,0 +1,32 @@
+package aoc
+
+import "fmt"
+
+// ParseError is returned by a Solver when the input cannot be parsed.
+type ParseError struct {
+	File   string // empty if the input is not a file
+	Line   int    // line number, starting at 1
+	Column int    // column, starting at 1. 0 if the error is about the whole line
+	Text   string // the offending text
+	Msg    string // what is wrong with it
+	Err    error  // the underlying error, if any
+}
+
+func (e *ParseError) Error() string {
+	pos := fmt.Sprintf("line %d", e.Line)
+	if e.File != "" {
+		pos = fmt.Sprintf("%s:%d", e.File, e.Line)
+	}
+	if e.Column > 0 {
+		pos += fmt.Sprintf(":%d", e.Column)
+	}
+	msg := fmt.Sprintf("%s: %s [%s]", pos, e.Msg, e.Text)
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
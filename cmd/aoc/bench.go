@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jpcornet/AoC2022/aoc"
+)
+
+// format a duration the way the runtimes are written in the README
+func readme_duration(d time.Duration) string {
+	switch {
+	case d < time.Microsecond:
+		return fmt.Sprintf("%dns", d)
+	case d < time.Millisecond:
+		return fmt.Sprintf("%dµs", d.Round(time.Microsecond)/time.Microsecond)
+	case d < 10*time.Millisecond:
+		return strings.TrimSuffix(fmt.Sprintf("%.1f", float64(d)/float64(time.Millisecond)), ".0") + "ms"
+	case d < 10*time.Second:
+		return fmt.Sprintf("%dms", d.Round(time.Millisecond)/time.Millisecond)
+	default:
+		return fmt.Sprintf("%ds", d.Round(time.Second)/time.Second)
+	}
+}
+
+// replace the runtimes listed in the README for a day with the median of the benchmark
+func update_readme(readme string, dayname string, res aoc.BenchResult) (string, error) {
+	// the runtimes are an indented block after the "Runtime" line
+	section_re := regexp.MustCompile(`(?s)\n\* day ` + regexp.QuoteMeta(dayname) + ` - .*?\nRuntime[^\n]*\n\n((?:    [^\n]*\n)+)`)
+	match := section_re.FindStringSubmatchIndex(readme)
+	if match == nil || strings.Contains(readme[match[0]+1:match[2]], "\n* ") {
+		return readme, fmt.Errorf("no runtimes for day %s in README", dayname)
+	}
+	total := res.Parse.Median + res.Part1.Median + res.Part2.Median
+	runtimes := fmt.Sprintf("    parsing: %s\n    part1: %s\n    part2: %s\n    Total: %s\n",
+		readme_duration(res.Parse.Median), readme_duration(res.Part1.Median), readme_duration(res.Part2.Median), readme_duration(total))
+	return readme[:match[2]] + runtimes + readme[match[3]:], nil
+}
+
+func bench(args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	runs := fs.Int("n", 10, "number of runs per day")
+	input := fs.String("input", "input.txt", "name of the input file in the input directory of each day")
+	readme := fs.String("readme", "", "update the runtimes in this README file with the median of each step")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *runs < 1 {
+		return errors.New("need at least 1 run")
+	}
+	benchdays := days
+	if fs.NArg() > 0 {
+		benchdays = nil
+		for _, name := range fs.Args() {
+			day, ok := find_day(name)
+			if !ok {
+				return fmt.Errorf("no Go solution for day %s", name)
+			}
+			benchdays = append(benchdays, day)
+		}
+	}
+	var readmetext string
+	if *readme != "" {
+		buf, err := os.ReadFile(*readme)
+		if err != nil {
+			return err
+		}
+		readmetext = string(buf)
+	}
+
+	// keep the output of the solvers out of the way
+	devnull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer devnull.Close()
+	stdout := os.Stdout
+	tw := tabwriter.NewWriter(stdout, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "day\tstep\tmin\tmedian\tp95\t\n")
+	for _, day := range benchdays {
+		// the day name is its directory, without the "day" prefix. Subdirectories share the input.
+		dir, _, _ := strings.Cut(day.name, "/")
+		filename := filepath.Join("day"+dir, "input", *input)
+		if _, err := os.Stat(filename); err != nil {
+			fmt.Fprintf(os.Stderr, "Skipping day %s: %s\n", day.name, err)
+			continue
+		}
+		os.Stdout = devnull
+		res, err := aoc.Bench(day.new, filename, *runs)
+		os.Stdout = stdout
+		if err != nil {
+			return err
+		}
+		for _, step := range []struct {
+			name  string
+			stats aoc.Stats
+		}{{"parse", res.Parse}, {"part1", res.Part1}, {"part2", res.Part2}} {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t\n", day.name, step.name, step.stats.Min, step.stats.Median, step.stats.P95)
+		}
+		if *readme != "" && !strings.Contains(day.name, "/") {
+			if readmetext, err = update_readme(readmetext, day.name, res); err != nil {
+				return err
+			}
+		}
+	}
+	tw.Flush()
+	if *readme != "" {
+		return os.WriteFile(*readme, []byte(readmetext), 0o644)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"github.com/jpcornet/AoC2022/aoc"
+	"github.com/jpcornet/AoC2022/day14"
+	"github.com/jpcornet/AoC2022/day15"
+	"github.com/jpcornet/AoC2022/day16"
+	"github.com/jpcornet/AoC2022/day17"
+	"github.com/jpcornet/AoC2022/day18"
+	"github.com/jpcornet/AoC2022/day19"
+	"github.com/jpcornet/AoC2022/day2"
+	"github.com/jpcornet/AoC2022/day20"
+	"github.com/jpcornet/AoC2022/day20/ll"
+	"github.com/jpcornet/AoC2022/day22"
+	"github.com/jpcornet/AoC2022/day23"
+	"github.com/jpcornet/AoC2022/day24"
+)
+
+type Day struct {
+	name string
+	new  func() aoc.Solver
+}
+
+// all days that have a Go solution. The name is the directory, without the "day" prefix.
+var days = []Day{
+	{"2", func() aoc.Solver { return day2.New() }},
+	{"14", func() aoc.Solver { return day14.New() }},
+	{"15", func() aoc.Solver { return day15.New() }},
+	{"16", func() aoc.Solver { return day16.New() }},
+	{"17", func() aoc.Solver { return day17.New() }},
+	{"18", func() aoc.Solver { return day18.New() }},
+	{"19", func() aoc.Solver { return day19.New() }},
+	{"20", func() aoc.Solver { return day20.New() }},
+	{"20/ll", func() aoc.Solver { return ll.New() }},
+	{"22", func() aoc.Solver { return day22.New() }},
+	{"23", func() aoc.Solver { return day23.New() }},
+	{"24", func() aoc.Solver { return day24.New() }},
+}
+
+func find_day(name string) (Day, bool) {
+	for _, d := range days {
+		if d.name == name {
+			return d, true
+		}
+	}
+	return Day{}, false
+}
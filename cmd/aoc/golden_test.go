@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jpcornet/AoC2022/aoc"
+	"github.com/jpcornet/AoC2022/aoc/aoctest"
+)
+
+// Expected answers for an input file, read from the sidecar file next to it.
+// For input/sample.txt, the sidecar file is input/sample.answers.json
+type Expected struct {
+	Args  []string `json:"args"`  // options for the day, as on the command line
+	Part1 any      `json:"part1"` // omit to not check the answer
+	Part2 any      `json:"part2"`
+	Slow  bool     `json:"slow"` // skip when running go test -short
+}
+
+const answers_suffix = ".answers.json"
+
+func read_expected(filename string) (Expected, error) {
+	var exp Expected
+	buf, err := os.ReadFile(filename)
+	if err != nil {
+		return exp, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	// keep the numbers as they are written, so they can be compared with the answers
+	dec.UseNumber()
+	dec.DisallowUnknownFields()
+	err = dec.Decode(&exp)
+	return exp, err
+}
+
+// Run every Go solution against all inputs that have expected answers
+func Test_golden(t *testing.T) {
+	aoctest.Quiet(t)
+	for _, day := range days {
+		dir, _, _ := strings.Cut(day.name, "/")
+		sidecars, err := filepath.Glob(filepath.Join("..", "..", "day"+dir, "input", "*"+answers_suffix))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, sidecar := range sidecars {
+			input := strings.TrimSuffix(sidecar, answers_suffix) + ".txt"
+			t.Run(day.name+"/"+filepath.Base(input), func(t *testing.T) {
+				exp, err := read_expected(sidecar)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if exp.Slow && testing.Short() {
+					t.Skip("slow input, skipped in short mode")
+				}
+				solver, args, err := new_solver(day.name, exp.Args)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(args) != 0 {
+					t.Fatalf("unexpected arguments %v", args)
+				}
+				res, err := aoc.Run(solver, input)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if exp.Part1 != nil && fmt.Sprint(res.Part1) != fmt.Sprint(exp.Part1) {
+					t.Errorf("part 1 = %v, want %v", res.Part1, exp.Part1)
+				}
+				if exp.Part2 != nil && fmt.Sprint(res.Part2) != fmt.Sprint(exp.Part2) {
+					t.Errorf("part 2 = %v, want %v", res.Part2, exp.Part2)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,116 @@
+// Command aoc runs the Go solutions of the Advent of Code 2022 puzzles.
+//
+// Usage:
+//
+//	aoc run [-format=text|json] <day> [day options] <inputfile>
+//	aoc bench [-n runs] [-input input.txt] [-readme README.md] [day ...]
+//	aoc list
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/jpcornet/AoC2022/aoc"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage:\n")
+	fmt.Fprintf(os.Stderr, "  %s run [-format=text|json] <day> [day options] <inputfile>\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s bench [-n runs] [-input input.txt] [-readme README.md] [day ...]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s list\n", os.Args[0])
+}
+
+// create the solver for a day, and parse the options specific for that day. Returns the remaining arguments.
+func new_solver(name string, args []string) (aoc.Solver, []string, error) {
+	day, ok := find_day(name)
+	if !ok {
+		return nil, nil, fmt.Errorf("no Go solution for day %s", name)
+	}
+	solver := day.new()
+	fs := flag.NewFlagSet("day "+name, flag.ContinueOnError)
+	if f, ok := solver.(aoc.Flagger); ok {
+		f.Flags(fs)
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+	return solver, fs.Args(), nil
+}
+
+// the result of a run, as written with -format=json
+type JSONResult struct {
+	Day   string `json:"day"`
+	Input string `json:"input"`
+	aoc.Result
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	format := fs.String("format", "text", "output format, text or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *format != "text" && *format != "json" {
+		return fmt.Errorf("unknown output format %s", *format)
+	}
+	args = fs.Args()
+	if len(args) < 1 {
+		return errors.New("provide day to run")
+	}
+	dayname := args[0]
+	solver, args, err := new_solver(dayname, args[1:])
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return errors.New("provide input file")
+	}
+	// the solvers print their progress on stdout. When writing json, move that out of the way.
+	stdout := os.Stdout
+	if *format == "json" {
+		os.Stdout = os.Stderr
+	}
+	res, err := aoc.Run(solver, args[0])
+	os.Stdout = stdout
+	if err != nil {
+		return err
+	}
+	if *format == "json" {
+		return json.NewEncoder(os.Stdout).Encode(JSONResult{Day: dayname, Input: args[0], Result: res})
+	}
+	fmt.Printf("part 1: %v\n", res.Part1)
+	fmt.Printf("part 2: %v\n", res.Part2)
+	fmt.Printf("Parse took: %s\n", res.ParseTime)
+	fmt.Printf("part 1 took: %s\n", res.Part1Time)
+	fmt.Printf("part 2 took: %s\n", res.Part2Time)
+	return nil
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	var err error
+	switch os.Args[1] {
+	case "run":
+		err = run(os.Args[2:])
+	case "bench":
+		err = bench(os.Args[2:])
+	case "list":
+		for _, d := range days {
+			fmt.Println(d.name)
+		}
+	default:
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", os.Args[0], err)
+		os.Exit(1)
+	}
+}
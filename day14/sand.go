@@ -1,12 +1,15 @@
-package main
+package day14
 
 import (
+	"bufio"
 	"fmt"
+	"io"
 	"math"
-	"os"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/jpcornet/AoC2022/aoc"
 )
 
 type Item uint8
@@ -34,39 +37,32 @@ type Coord struct {
 
 type Line []Coord
 
-func parse_input(filename string, pyramid_top int) Field {
+func parse_input(r io.Reader, pyramid_top int) (Field, error) {
 	starttime := time.Now()
-	inputstr, err := os.ReadFile(filename)
-	if err != nil {
-		panic(err)
-	}
-	inputlines := strings.Split(string(inputstr[:]), "\n")
-	// remove last empty line
-	if len(inputlines[len(inputlines)-1]) == 0 {
-		inputlines = inputlines[0 : len(inputlines)-1]
-	}
-	linesplittime := time.Now()
+	scanner := bufio.NewScanner(r)
 	lines := make([]Line, 0, 500)
 	minx := math.MaxInt
 	maxx := math.MinInt
 	maxy := math.MinInt
-	for _, linestr := range inputlines {
+	for lnr := 0; scanner.Scan(); lnr++ {
+		linestr := scanner.Text()
 		newline := make(Line, 0, 40)
 		coords := strings.Split(linestr, " -> ")
+		col := 0
 		for _, coord := range coords {
 			strx, stry, ok := strings.Cut(coord, ",")
 			if !ok {
-				panic(fmt.Sprintf("No , in coord %s", coord))
+				return Field{}, &aoc.ParseError{Line: lnr + 1, Column: col + 1, Text: coord, Msg: "no , in coord"}
 			}
 			var c Coord
 			var err error
 			c.x, err = strconv.Atoi(strx)
 			if err != nil {
-				panic(err)
+				return Field{}, &aoc.ParseError{Line: lnr + 1, Column: col + 1, Text: strx, Msg: "invalid x coord", Err: err}
 			}
 			c.y, err = strconv.Atoi(stry)
 			if err != nil {
-				panic(err)
+				return Field{}, &aoc.ParseError{Line: lnr + 1, Column: col + len(strx) + 2, Text: stry, Msg: "invalid y coord", Err: err}
 			}
 			if c.x < minx {
 				minx = c.x
@@ -78,9 +74,16 @@ func parse_input(filename string, pyramid_top int) Field {
 				maxy = c.y
 			}
 			newline = append(newline, c)
+			col += len(coord) + len(" -> ")
 		}
 		lines = append(lines, newline)
 	}
+	if err := scanner.Err(); err != nil {
+		return Field{}, err
+	}
+	if len(lines) == 0 {
+		return Field{}, &aoc.ParseError{Line: 1, Msg: "no rock lines"}
+	}
 	// Make sure enough room around the edges is free
 	minx -= 1
 	maxx += 1
@@ -112,9 +115,9 @@ func parse_input(filename string, pyramid_top int) Field {
 	draw_rocks(&field, lines)
 	field.grains = 0
 	drawtime := time.Now()
-	fmt.Printf("Reading to lines took: %s, parsing lines took: %s, alloc field took: %s, drawing rocks took: %s\n",
-		linesplittime.Sub(starttime), lineparsetime.Sub(linesplittime), alloctime.Sub(lineparsetime), drawtime.Sub(alloctime))
-	return field
+	fmt.Printf("Reading and parsing lines took: %s, alloc field took: %s, drawing rocks took: %s\n",
+		lineparsetime.Sub(starttime), alloctime.Sub(lineparsetime), drawtime.Sub(alloctime))
+	return field, nil
 }
 
 func draw_rocks(field *Field, lines []Line) {
@@ -186,22 +189,35 @@ func show_field(f Field) {
 	}
 }
 
-func main() {
-	if len(os.Args) != 2 {
-		panic("Provide input file")
+// Solver solves the puzzle of day 14
+type Solver struct {
+	field Field
+}
+
+func New() *Solver {
+	return &Solver{}
+}
+
+func (s *Solver) Parse(r io.Reader) error {
+	var err error
+	s.field, err = parse_input(r, 500)
+	if err != nil {
+		return err
 	}
-	starttime := time.Now()
-	field := parse_input(os.Args[1], 500)
 	// draw the extra bottom line
+	field := &s.field
 	bottom := []Coord{{field.xoffset, field.yoffset + field.ysize - 1}, {field.xoffset + field.xsize - 1, field.yoffset + field.ysize - 1}}
 	bottomlines := []Line{bottom}
-	draw_rocks(&field, bottomlines)
-	parsetime := time.Now()
-	drop_sand(&field, Coord{500, 0})
-	part2time := time.Now()
-	show_field(field)
-
-	fmt.Printf("Number of sand part 1: %d\n", field.part1)
-	fmt.Printf("Number of sand part 2: %d\n", field.grains)
-	fmt.Printf("took: parsing=%s pouring sand=%s\n", parsetime.Sub(starttime), part2time.Sub(parsetime))
+	draw_rocks(field, bottomlines)
+	return nil
+}
+
+// Part1 pours all the sand, which also calculates the answer for part 2
+func (s *Solver) Part1() any {
+	drop_sand(&s.field, Coord{500, 0})
+	return s.field.part1
+}
+
+func (s *Solver) Part2() any {
+	return s.field.grains
 }
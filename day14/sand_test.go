@@ -0,0 +1,42 @@
+package day14
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jpcornet/AoC2022/aoc"
+	"github.com/jpcornet/AoC2022/aoc/aoctest"
+)
+
+func Test_parse_input_errors(t *testing.T) {
+	tests := []struct {
+		name         string
+		input        string
+		line, column int
+		text         string
+	}{
+		{name: "empty", input: "", line: 1},
+		{name: "no comma", input: "498,4 -> 498,6\n503,4 -> 502 4\n", line: 2, column: 10, text: "502 4"},
+		{name: "invalid x", input: "498,4 -> x98,6\n", line: 1, column: 10, text: "x98"},
+		{name: "invalid y", input: "498,4 -> 498,6y\n", line: 1, column: 14, text: "6y"},
+		{name: "empty line", input: "498,4 -> 498,6\n\n", line: 2, column: 1, text: ""},
+	}
+	aoctest.Quiet(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parse_input(strings.NewReader(tt.input), 500)
+			var perr *aoc.ParseError
+			if !errors.As(err, &perr) {
+				t.Fatalf("parse_input() error = %v, want a ParseError", err)
+			}
+			if perr.Line != tt.line || perr.Column != tt.column || perr.Text != tt.text {
+				t.Errorf("parse_input() error at %d:%d [%s], want %d:%d [%s]", perr.Line, perr.Column, perr.Text, tt.line, tt.column, tt.text)
+			}
+		})
+	}
+}
+
+func BenchmarkSolver(b *testing.B) {
+	aoctest.Benchmark(b, func() aoc.Solver { return New() }, aoctest.BenchInput("input"))
+}
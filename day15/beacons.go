@@ -1,12 +1,15 @@
-package main
+package day15
 
 import (
+	"bufio"
+	"flag"
 	"fmt"
+	"io"
 	"math"
-	"os"
 	"strconv"
 	"strings"
-	"time"
+
+	"github.com/jpcornet/AoC2022/aoc"
 )
 
 type Sensor struct {
@@ -25,78 +28,78 @@ func intabs(x int) int {
 	}
 }
 
-func parse_input(filename string) []Sensor {
-	inputstr, err := os.ReadFile(filename)
-	if err != nil {
-		panic(err)
-	}
-	inputlines := strings.Split(string(inputstr[:]), "\n")
-	// remove last empty line
-	if len(inputlines[len(inputlines)-1]) == 0 {
-		inputlines = inputlines[0 : len(inputlines)-1]
-	}
+func parse_input(r io.Reader) ([]Sensor, error) {
+	scanner := bufio.NewScanner(r)
 	sensors := make([]Sensor, 0, 500)
-	for _, linestr := range inputlines {
+	for lnr := 0; scanner.Scan(); lnr++ {
+		linestr := scanner.Text()
+		// report an error at the given column in the current line
+		parse_error := func(col int, msg string, err error) error {
+			return &aoc.ParseError{Line: lnr + 1, Column: col + 1, Text: linestr[col:], Msg: msg, Err: err}
+		}
 		var err error
 		var sx, sy, bx, by int
 		startstring := "Sensor at x="
 		if !strings.HasPrefix(linestr, startstring) {
-			panic("Invalid input 1")
+			return nil, parse_error(0, fmt.Sprintf("expected [%s]", startstring), nil)
 		}
 		num_start := len(startstring)
 		sep_at := strings.IndexRune(linestr[num_start:], ',')
 		if sep_at == -1 {
-			panic("Invalid input 1b")
+			return nil, parse_error(num_start, "no , after sensor x", nil)
 		}
 		sx, err = strconv.Atoi(linestr[num_start : num_start+sep_at])
 		if err != nil {
-			panic(err)
+			return nil, parse_error(num_start, "invalid sensor x", err)
 		}
 
 		commaystring := ", y="
 		num_start += sep_at
 		if !strings.HasPrefix(linestr[num_start:], ", y=") {
-			panic("Invalid input 2")
+			return nil, parse_error(num_start, fmt.Sprintf("expected [%s]", commaystring), nil)
 		}
 		num_start += len(commaystring)
 		sep_at = strings.IndexRune(linestr[num_start:], ':')
 		if sep_at == -1 {
-			panic("Invalid input 2b")
+			return nil, parse_error(num_start, "no : after sensor y", nil)
 		}
 		sy, err = strconv.Atoi(linestr[num_start : num_start+sep_at])
 		if err != nil {
-			panic(err)
+			return nil, parse_error(num_start, "invalid sensor y", err)
 		}
 
 		beaconstring := ": closest beacon is at x="
 		num_start += sep_at
 		if !strings.HasPrefix(linestr[num_start:], beaconstring) {
-			panic("Invalid input 3")
+			return nil, parse_error(num_start, fmt.Sprintf("expected [%s]", beaconstring), nil)
 		}
 		num_start += len(beaconstring)
 		sep_at = strings.IndexRune(linestr[num_start:], ',')
 		if sep_at == -1 {
-			panic("Invalid input 3b")
+			return nil, parse_error(num_start, "no , after beacon x", nil)
 		}
 		bx, err = strconv.Atoi(linestr[num_start : num_start+sep_at])
 		if err != nil {
-			panic(err)
+			return nil, parse_error(num_start, "invalid beacon x", err)
 		}
 
 		num_start += sep_at
 		if !strings.HasPrefix(linestr[num_start:], commaystring) {
-			panic("Invalid input 4")
+			return nil, parse_error(num_start, fmt.Sprintf("expected [%s]", commaystring), nil)
 		}
 		num_start += len(commaystring)
 		by, err = strconv.Atoi(linestr[num_start:])
 		if err != nil {
-			panic(err)
+			return nil, parse_error(num_start, "invalid beacon y", err)
 		}
 
 		sensors = append(sensors, Sensor{x: sx, y: sy, beaconx: bx, beacony: by, dist: intabs(sx-bx) + intabs(sy-by)})
 		//fmt.Printf("Parsed sensor: %v\n", sensors[len(sensors)-1])
 	}
-	return sensors
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return sensors, nil
 }
 
 // Only return sensors that are in range of the given y coord
@@ -215,28 +218,29 @@ func part2(sensors []Sensor, maxy int) int {
 	return -1
 }
 
-func main() {
-	if len(os.Args) <= 1 {
-		panic("Provide input file")
-	}
-	starttime := time.Now()
-	sensors := parse_input(os.Args[1])
-	y := 2000000
-	if len(os.Args) == 3 {
-		var err error
-		y, err = strconv.Atoi(os.Args[2])
-		if err != nil {
-			panic(err)
-		}
-	}
-	parsetime := time.Now()
-	answ := part1(sensors, y)
-	part1time := time.Now()
-	answ2 := part2(sensors, y*2)
-	part2time := time.Now()
-	fmt.Printf("Part 1, total excluded on line %d is: %d\n", y, answ)
-	fmt.Printf("Part 2, frequency of single gap: %d\n", answ2)
-	fmt.Printf("Parsing took: %s\n", parsetime.Sub(starttime))
-	fmt.Printf("Part1 took: %s\n", part1time.Sub(parsetime))
-	fmt.Printf("Part2 took: %s\n", part2time.Sub(part1time))
+// Solver solves the puzzle of day 15
+type Solver struct {
+	sensors []Sensor
+	y       int
+}
+
+func New() *Solver {
+	return &Solver{y: 2000000}
+}
+
+func (s *Solver) Flags(fs *flag.FlagSet) {
+	fs.IntVar(&s.y, "y", s.y, "line to count excluded positions on. Part 2 searches up to twice this")
+}
+
+func (s *Solver) Parse(r io.Reader) (err error) {
+	s.sensors, err = parse_input(r)
+	return err
+}
+
+func (s *Solver) Part1() any {
+	return part1(s.sensors, s.y)
+}
+
+func (s *Solver) Part2() any {
+	return part2(s.sensors, s.y*2)
 }
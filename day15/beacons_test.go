@@ -0,0 +1,66 @@
+package day15
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jpcornet/AoC2022/aoc"
+	"github.com/jpcornet/AoC2022/aoc/aoctest"
+)
+
+func Test_parse_input_errors(t *testing.T) {
+	tests := []struct {
+		name         string
+		input        string
+		line, column int
+		text         string
+	}{
+		{
+			name:   "no sensor",
+			input:  "Sensor at x=2, y=18: closest beacon is at x=-2, y=15\nSensr at x=9, y=16: closest beacon is at x=10, y=16\n",
+			line:   2,
+			column: 1,
+			text:   "Sensr at x=9, y=16: closest beacon is at x=10, y=16",
+		},
+		{
+			name:   "no colon",
+			input:  "Sensor at x=2, y=18 closest beacon is at x=-2, y=15\n",
+			line:   1,
+			column: 18,
+			text:   "18 closest beacon is at x=-2, y=15",
+		},
+		{
+			name:   "invalid beacon y",
+			input:  "Sensor at x=2, y=18: closest beacon is at x=-2, y=1a5\n",
+			line:   1,
+			column: 51,
+			text:   "1a5",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parse_input(strings.NewReader(tt.input))
+			var perr *aoc.ParseError
+			if !errors.As(err, &perr) {
+				t.Fatalf("parse_input() error = %v, want a ParseError", err)
+			}
+			if perr.Line != tt.line || perr.Column != tt.column || perr.Text != tt.text {
+				t.Errorf("parse_input() error at %d:%d [%s], want %d:%d [%s]", perr.Line, perr.Column, perr.Text, tt.line, tt.column, tt.text)
+			}
+		})
+	}
+}
+
+func BenchmarkSolver(b *testing.B) {
+	input := aoctest.BenchInput("input")
+	aoctest.Benchmark(b, func() aoc.Solver {
+		s := New()
+		if filepath.Base(input) == "sample.txt" {
+			// the sample uses a different line than the real input
+			s.y = 10
+		}
+		return s
+	}, input)
+}
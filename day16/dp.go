@@ -0,0 +1,222 @@
+package day16
+
+// Alternative to the search: dynamic programming over the position, the set of opened valves and the time left.
+// This gives the best pressure for every set of valves one agent can open. Several agents each take a set of
+// valves, the sets do not overlap.
+
+// the best pressure is kept for every set of valves with a non-zero flowrate, so this takes 2^n entries
+const max_dp_valves = 24
+
+// every agent after the second tries all subsets of every set of valves, that takes 3^n steps
+const max_dp_valves_agents = 16
+
+// the most valves with a non-zero flowrate the dp solver can handle for this many agents
+func dp_valve_limit(agents int) int {
+	if agents > 2 {
+		return max_dp_valves_agents
+	}
+	return max_dp_valves
+}
+
+type DPKey struct {
+	pos    Valvenr
+	opened uint32 // bitset of the opened valves
+}
+
+type DPState struct {
+	pressure int
+	prev     DPKey // where we came from, to find the way back
+	prevtime int
+}
+
+type DPTable struct {
+	start    Valvenr
+	timeleft int
+	bitvalve []Valvenr           // the valve for each bit in the set of opened valves
+	layers   []map[DPKey]DPState // the states for each amount of time left
+	best     []int               // best pressure for each set of opened valves
+	endtime  []int               // time left when the last valve of the set is opened, -1 if the set cannot be opened
+	endpos   []Valvenr           // the last valve opened
+}
+
+// the distance between all valves in the reduced vulcano, -1 if there is no way
+func distances(rv ReducedVulcano) [][]int {
+	dist := make([][]int, len(rv.valves))
+	for vnr, valve := range rv.valves {
+		dist[vnr] = make([]int, len(rv.valves))
+		for i := range dist[vnr] {
+			dist[vnr][i] = -1
+		}
+		dist[vnr][vnr] = 0
+		for _, tun := range valve.tunnel {
+			dist[vnr][tun.valve] = tun.dist
+		}
+	}
+	return dist
+}
+
+// number of valves that can be opened, and have to be kept in the bitset
+func dp_valves(rv ReducedVulcano) int {
+	count := 0
+	for _, valve := range rv.valves {
+		if valve.flowrate > 0 {
+			count++
+		}
+	}
+	return count
+}
+
+func dp_table(rv ReducedVulcano, start string, timeleft int) DPTable {
+	dt := DPTable{start: rv.valvenr[start], timeleft: timeleft}
+	for vnr, valve := range rv.valves {
+		if valve.flowrate > 0 {
+			dt.bitvalve = append(dt.bitvalve, Valvenr(vnr))
+		}
+	}
+	dist := distances(rv)
+	dt.best = make([]int, 1<<len(dt.bitvalve))
+	dt.endtime = make([]int, len(dt.best))
+	dt.endpos = make([]Valvenr, len(dt.best))
+	for i := range dt.endtime {
+		dt.endtime[i] = -1
+	}
+	dt.endtime[0] = timeleft
+	dt.endpos[0] = dt.start
+	dt.layers = make([]map[DPKey]DPState, timeleft+1)
+	dt.layers[timeleft] = map[DPKey]DPState{{pos: dt.start}: {}}
+	// time only goes down, so every layer is complete before it is expanded
+	for t := timeleft; t > 0; t-- {
+		for key, state := range dt.layers[t] {
+			for bit, vnr := range dt.bitvalve {
+				if key.opened&(1<<bit) != 0 || dist[key.pos][vnr] < 0 {
+					continue
+				}
+				// go there and open it, if there is time left after that
+				newtime := t - dist[key.pos][vnr] - rv.valves[vnr].opentime
+				if newtime <= 0 {
+					continue
+				}
+				newkey := DPKey{pos: vnr, opened: key.opened | 1<<bit}
+				pressure := state.pressure + rv.valves[vnr].flowrate*newtime
+				if dt.layers[newtime] == nil {
+					dt.layers[newtime] = make(map[DPKey]DPState)
+				}
+				if old, seen := dt.layers[newtime][newkey]; seen && old.pressure >= pressure {
+					continue
+				}
+				dt.layers[newtime][newkey] = DPState{pressure: pressure, prev: key, prevtime: t}
+				if dt.endtime[newkey.opened] < 0 || pressure > dt.best[newkey.opened] {
+					dt.best[newkey.opened] = pressure
+					dt.endtime[newkey.opened] = newtime
+					dt.endpos[newkey.opened] = vnr
+				}
+			}
+		}
+	}
+	return dt
+}
+
+// the path walked by one agent to open the given set of valves, and the time left at the end
+func (dt DPTable) path(set uint32) (Path, int) {
+	var visited []Valvenr
+	key := DPKey{pos: dt.endpos[set], opened: set}
+	for t := dt.endtime[set]; t < dt.timeleft; {
+		visited = append(visited, key.pos)
+		state := dt.layers[t][key]
+		key, t = state.prev, state.prevtime
+	}
+	path := Path{{valve: dt.start}}
+	for i := len(visited) - 1; i >= 0; i-- {
+		if visited[i] != path[len(path)-1].valve || path[len(path)-1].open {
+			path = append(path, Step{valve: visited[i]})
+		}
+		path = append(path, Step{valve: visited[i], open: true})
+	}
+	return path, dt.endtime[set]
+}
+
+// the best pressure for a number of agents that each open a separate set of valves, and those sets
+func (dt DPTable) combine(agents int) (int, []uint32) {
+	full := uint32(len(dt.best) - 1)
+	// best pressure when opening a subset of each set of valves, and which subset that is
+	subbest := make([]int, len(dt.best))
+	subset := make([]uint32, len(dt.best))
+	for opened := range dt.best {
+		if dt.endtime[opened] >= 0 {
+			subbest[opened] = dt.best[opened]
+			subset[opened] = uint32(opened)
+		}
+	}
+	for bit := 0; bit < len(dt.bitvalve); bit++ {
+		for opened := range subbest {
+			if opened&(1<<bit) != 0 && subbest[opened^1<<bit] > subbest[opened] {
+				subbest[opened] = subbest[opened^1<<bit]
+				subset[opened] = subset[opened^1<<bit]
+			}
+		}
+	}
+	// best pressure for a agents within each set of valves, and what the last of them takes
+	group := subbest
+	var takes [][]uint32
+	for a := 2; a < agents; a++ {
+		newgroup := make([]int, len(group))
+		take := make([]uint32, len(group))
+		for opened := range group {
+			// try every subset for the added agent
+			for s := uint32(opened); ; s = (s - 1) & uint32(opened) {
+				if pressure := subbest[s] + group[uint32(opened)^s]; pressure > newgroup[opened] {
+					newgroup[opened] = pressure
+					take[opened] = s
+				}
+				if s == 0 {
+					break
+				}
+			}
+		}
+		group = newgroup
+		takes = append(takes, take)
+	}
+	// the last agent takes a set, the others what remains of all valves
+	pressure, last := subbest[full], full
+	if agents > 1 {
+		pressure = -1
+		for s := range subbest {
+			if p := subbest[s] + group[full^uint32(s)]; p > pressure {
+				pressure, last = p, uint32(s)
+			}
+		}
+	}
+	sets := make([]uint32, agents)
+	sets[agents-1] = subset[last]
+	remaining := full ^ last
+	for a := agents - 2; a > 0; a-- {
+		take := takes[a-1][remaining]
+		sets[a] = subset[take]
+		remaining ^= take
+	}
+	if agents > 1 {
+		sets[0] = subset[remaining]
+	}
+	return pressure, sets
+}
+
+// find max flow using dynamic programming. The agents all start at the same valve.
+func findmaxflow_dp(rv ReducedVulcano, start string, timeleft int, agents int) Solution {
+	dt := dp_table(rv, start, timeleft)
+	pressure, sets := dt.combine(agents)
+	solution := Solution{
+		pressure: pressure,
+		timeleft: make([]int, agents),
+		path:     make([]Path, agents),
+		is_open:  new_valveset(len(rv.valves)),
+	}
+	for agent, opened := range sets {
+		solution.path[agent], solution.timeleft[agent] = dt.path(opened)
+		for bit, vnr := range dt.bitvalve {
+			if opened&(1<<bit) != 0 {
+				solution.is_open = solution.is_open.with(vnr)
+			}
+		}
+	}
+	return solution
+}
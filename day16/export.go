@@ -0,0 +1,203 @@
+package day16
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Export the vulcano as Graphviz DOT and as JSON, with the route of the best solution in it.
+// Both the original tunnels and the distances in the reduced vulcano are in there.
+
+// colors of the route of each agent
+var agent_colors = []string{"red", "blue", "darkgreen", "orange", "purple", "brown"}
+
+// the shortest walk through the tunnels from one valve to another, excluding the valve we start at
+func tunnel_walk(vl Vulcano, from string, to string) []string {
+	_, _, came_from := shortest_paths(from, vl)
+	var walk []string
+	for pos := to; pos != from; pos = came_from[pos] {
+		walk = append(walk, pos)
+	}
+	for i, j := 0, len(walk)-1; i < j; i, j = i+1, j-1 {
+		walk[i], walk[j] = walk[j], walk[i]
+	}
+	return walk
+}
+
+type ExportTunnel struct {
+	To     string `json:"to"`
+	Length int    `json:"length"`
+}
+
+type ExportValve struct {
+	Name     string         `json:"name"`
+	Flowrate int            `json:"flowrate"`
+	Opentime int            `json:"opentime"`
+	Tunnels  []ExportTunnel `json:"tunnels"`
+}
+
+type ExportDistance struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Dist int    `json:"dist"`
+}
+
+type ExportRoute struct {
+	Walk     []string `json:"walk"`   // every valve visited in the original tunnels, starting at the start valve
+	Opened   []string `json:"opened"` // the valves opened, in order
+	Timeleft int      `json:"timeleft"`
+}
+
+type Export struct {
+	Start     string           `json:"start"`
+	Pressure  int              `json:"pressure"`
+	Valves    []ExportValve    `json:"valves"`    // the original vulcano
+	Distances []ExportDistance `json:"distances"` // the reduced vulcano
+	Routes    []ExportRoute    `json:"routes"`    // the route of each agent
+}
+
+func make_export(vl Vulcano, rv ReducedVulcano, start string, sol Solution) Export {
+	exp := Export{Start: start, Pressure: sol.pressure}
+	for name, valve := range vl.valves {
+		ev := ExportValve{Name: name, Flowrate: valve.flowrate, Opentime: valve.opentime}
+		for _, tun := range valve.tunnel {
+			ev.Tunnels = append(ev.Tunnels, ExportTunnel{To: tun.to, Length: tun.length})
+		}
+		exp.Valves = append(exp.Valves, ev)
+	}
+	sort.Slice(exp.Valves, func(i, j int) bool { return exp.Valves[i].Name < exp.Valves[j].Name })
+	for _, valve := range rv.valves {
+		for _, tun := range valve.tunnel {
+			if to := rv.valves[tun.valve].name; valve.name < to {
+				exp.Distances = append(exp.Distances, ExportDistance{From: valve.name, To: to, Dist: tun.dist})
+			}
+		}
+	}
+	sort.Slice(exp.Distances, func(i, j int) bool {
+		if exp.Distances[i].From != exp.Distances[j].From {
+			return exp.Distances[i].From < exp.Distances[j].From
+		}
+		return exp.Distances[i].To < exp.Distances[j].To
+	})
+	for agent, path := range sol.path {
+		route := ExportRoute{Walk: []string{start}, Opened: []string{}, Timeleft: sol.timeleft[agent]}
+		for _, step := range path[1:] {
+			name := rv.valves[step.valve].name
+			if step.open {
+				route.Opened = append(route.Opened, name)
+			} else {
+				route.Walk = append(route.Walk, tunnel_walk(vl, route.Walk[len(route.Walk)-1], name)...)
+			}
+		}
+		exp.Routes = append(exp.Routes, route)
+	}
+	return exp
+}
+
+// both ends of a tunnel, in a fixed order
+func edge(a string, b string) [2]string {
+	if b < a {
+		return [2]string{b, a}
+	}
+	return [2]string{a, b}
+}
+
+func (exp Export) dot() string {
+	// color every valve opened, and every tunnel walked, in the color of the agent
+	opened := make(map[string]string)
+	walked := make(map[[2]string]string)
+	reduced := make(map[[2]string]string)
+	for agent, route := range exp.Routes {
+		color := agent_colors[agent%len(agent_colors)]
+		for _, name := range route.Opened {
+			opened[name] = color
+		}
+		for i := 1; i < len(route.Walk); i++ {
+			walked[edge(route.Walk[i-1], route.Walk[i])] = color
+		}
+		// in the reduced vulcano, the route goes straight from one opened valve to the next
+		prev := exp.Start
+		for _, name := range route.Opened {
+			if name != prev {
+				reduced[edge(prev, name)] = color
+			}
+			prev = name
+		}
+	}
+	node := func(prefix string, v ExportValve) string {
+		label := fmt.Sprintf(`%s\n%d`, v.Name, v.Flowrate)
+		if v.Opentime != 1 {
+			label += fmt.Sprintf(` (%d min)`, v.Opentime)
+		}
+		attrs := []string{fmt.Sprintf(`label="%s"`, label)}
+		if v.Name == exp.Start {
+			attrs = append(attrs, "shape=doublecircle")
+		}
+		if color, ok := opened[v.Name]; ok {
+			attrs = append(attrs, "penwidth=3", "color="+color)
+		}
+		return fmt.Sprintf("\t\t%s_%s [%s];\n", prefix, v.Name, strings.Join(attrs, ", "))
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "graph vulcano {\n")
+	fmt.Fprintf(&sb, "\tlabel=\"pressure %d\";\n", exp.Pressure)
+	fmt.Fprintf(&sb, "\tsubgraph cluster_tunnels {\n\t\tlabel=\"tunnels\";\n")
+	seen := make(map[[2]string]bool)
+	for _, v := range exp.Valves {
+		sb.WriteString(node("t", v))
+	}
+	for _, v := range exp.Valves {
+		for _, tun := range v.Tunnels {
+			e := edge(v.Name, tun.To)
+			if seen[e] {
+				continue
+			}
+			seen[e] = true
+			var attrs []string
+			if tun.Length != 1 {
+				attrs = append(attrs, fmt.Sprintf("label=%d", tun.Length))
+			}
+			if color, ok := walked[e]; ok {
+				attrs = append(attrs, "penwidth=3", "color="+color)
+			}
+			if len(attrs) == 0 {
+				fmt.Fprintf(&sb, "\t\tt_%s -- t_%s;\n", e[0], e[1])
+			} else {
+				fmt.Fprintf(&sb, "\t\tt_%s -- t_%s [%s];\n", e[0], e[1], strings.Join(attrs, ", "))
+			}
+		}
+	}
+	fmt.Fprintf(&sb, "\t}\n")
+	fmt.Fprintf(&sb, "\tsubgraph cluster_reduced {\n\t\tlabel=\"reduced\";\n")
+	for _, v := range exp.Valves {
+		if v.Flowrate > 0 || v.Name == exp.Start {
+			sb.WriteString(node("r", v))
+		}
+	}
+	for _, d := range exp.Distances {
+		attrs := fmt.Sprintf("label=%d", d.Dist)
+		if color, ok := reduced[edge(d.From, d.To)]; ok {
+			attrs += ", penwidth=3, color=" + color
+		} else {
+			attrs += ", color=grey"
+		}
+		fmt.Fprintf(&sb, "\t\tr_%s -- r_%s [%s];\n", d.From, d.To, attrs)
+	}
+	fmt.Fprintf(&sb, "\t}\n}\n")
+	return sb.String()
+}
+
+// write the export as prefix.dot and prefix.json
+func write_export(prefix string, exp Export) error {
+	if err := os.WriteFile(prefix+".dot", []byte(exp.dot()), 0o644); err != nil {
+		return err
+	}
+	buf, err := json.MarshalIndent(exp, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(prefix+".json", append(buf, '\n'), 0o644)
+}
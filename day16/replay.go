@@ -0,0 +1,124 @@
+package day16
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Replay a solution minute by minute, walking through the original tunnels. This does not use the distances of the
+// reduced vulcano, so it checks the pressure found by the solvers.
+
+// what an agent does in one minute: move to a valve, or open the valve it is at.
+// Both can take more than a minute, done is set in the last minute.
+type Action struct {
+	valve string
+	open  bool
+	done  bool
+}
+
+// the time it takes to walk from one valve to the next
+func tunnel_length(vl Vulcano, from string, to string) int {
+	length := 0
+	for _, tun := range vl.valves[from].tunnel {
+		if tun.to == to && (length == 0 || tun.length < length) {
+			length = tun.length
+		}
+	}
+	return length
+}
+
+// the actions of one agent for every minute, following the path through the original tunnels
+func actions(vl Vulcano, rv ReducedVulcano, start string, path Path) []Action {
+	var acts []Action
+	// add an action that takes a number of minutes
+	add := func(act Action, minutes int) {
+		for m := 1; m <= minutes; m++ {
+			act.done = m == minutes
+			acts = append(acts, act)
+		}
+	}
+	pos := start
+	for _, step := range path[1:] {
+		name := rv.valves[step.valve].name
+		if step.open {
+			add(Action{valve: name, open: true}, vl.valves[name].opentime)
+			continue
+		}
+		for _, next := range tunnel_walk(vl, pos, name) {
+			add(Action{valve: next}, tunnel_length(vl, pos, next))
+			pos = next
+		}
+	}
+	return acts
+}
+
+// replay the solution, writing what happens each minute. Returns the total pressure released.
+func replay(w io.Writer, vl Vulcano, rv ReducedVulcano, start string, minutes int, sol Solution) (int, error) {
+	agent_actions := make([][]Action, len(sol.path))
+	pos := make([]string, len(sol.path))
+	for agent, path := range sol.path {
+		agent_actions[agent] = actions(vl, rv, start, path)
+		if len(agent_actions[agent]) > minutes {
+			return 0, fmt.Errorf("agent %d needs %d minutes, only %d available", agent+1, len(agent_actions[agent]), minutes)
+		}
+		pos[agent] = start
+	}
+	is_open := make(map[string]bool)
+	total := 0
+	for minute := 1; minute <= minutes; minute++ {
+		// valves opened before this minute release pressure
+		released := 0
+		open := make([]string, 0, len(is_open))
+		for name := range is_open {
+			released += vl.valves[name].flowrate
+			open = append(open, name)
+		}
+		sort.Strings(open)
+		total += released
+		fmt.Fprintf(w, "== Minute %d ==\n", minute)
+		if len(open) == 0 {
+			fmt.Fprintf(w, "No valves are open.\n")
+		} else if len(open) == 1 {
+			fmt.Fprintf(w, "Valve %s is open, releasing %d pressure.\n", open[0], released)
+		} else {
+			fmt.Fprintf(w, "Valves %s are open, releasing %d pressure.\n", strings.Join(open, ", "), released)
+		}
+		for agent, acts := range agent_actions {
+			who, s := "You", ""
+			if len(agent_actions) > 1 {
+				who, s = fmt.Sprintf("Agent %d", agent+1), "s"
+			}
+			if minute > len(acts) {
+				fmt.Fprintf(w, "%s stay%s at valve %s.\n", who, s, pos[agent])
+				continue
+			}
+			act := acts[minute-1]
+			if !act.done {
+				doing := "walking to"
+				if act.open {
+					doing = "opening"
+				}
+				fmt.Fprintf(w, "%s keep%s %s valve %s.\n", who, s, doing, act.valve)
+				continue
+			}
+			if !act.open {
+				pos[agent] = act.valve
+				fmt.Fprintf(w, "%s move%s to valve %s.\n", who, s, act.valve)
+				continue
+			}
+			if is_open[act.valve] {
+				return total, fmt.Errorf("minute %d: %s opens valve %s, which is already open", minute, who, act.valve)
+			}
+			is_open[act.valve] = true
+			fmt.Fprintf(w, "%s open%s valve %s.\n", who, s, act.valve)
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "Total pressure released: %d\n", total)
+	if total != sol.pressure {
+		return total, fmt.Errorf("replay releases %d pressure, the solution says %d", total, sol.pressure)
+	}
+	return total, nil
+}
@@ -1,18 +1,33 @@
-package main
+package day16
 
 import (
+	"bufio"
+	"container/heap"
+	"flag"
 	"fmt"
+	"io"
 	"os"
 	"regexp"
+	"runtime"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
-	"time"
+	"sync"
+	"sync/atomic"
+
+	"github.com/jpcornet/AoC2022/aoc"
 )
 
+type Tunnel struct {
+	to     string
+	length int // minutes it takes to walk through the tunnel
+}
+
 type Valve struct {
 	flowrate int
-	tunnel   []string
+	opentime int // minutes it takes to open the valve
+	tunnel   []Tunnel
 }
 
 type Vulcano struct {
@@ -23,11 +38,36 @@ type Vulcano struct {
 type Visited map[string]bool
 
 // for a simplified vulcano
-type Valvenr uint8
+type Valvenr uint16
+
+const max_valves = 1 << 16
+
+// a step in a path: walk to a valve, or open the valve where we are
+type Step struct {
+	valve Valvenr
+	open  bool
+}
+
+type Path []Step
+
+// set of valves, with one bit for each valve
+type Valveset []uint64
 
-const opened Valvenr = ^Valvenr(0) ^ (^Valvenr(0) >> 1) // 0x80
+func new_valveset(valves int) Valveset {
+	return make(Valveset, (valves+63)/64)
+}
 
-type Path []Valvenr
+func (vs Valveset) has(vnr Valvenr) bool {
+	return vs[vnr/64]&(1<<(vnr%64)) != 0
+}
+
+// a copy of the set, with one valve added
+func (vs Valveset) with(vnr Valvenr) Valveset {
+	new_vs := make(Valveset, len(vs))
+	copy(new_vs, vs)
+	new_vs[vnr/64] |= 1 << (vnr % 64)
+	return new_vs
+}
 
 type TunnelElem struct {
 	dist  int
@@ -37,93 +77,146 @@ type TunnelElem struct {
 type RValve struct {
 	name     string
 	flowrate int
+	opentime int
 	tunnel   []TunnelElem
 }
 type ReducedVulcano struct {
 	valvenr map[string]Valvenr
 	valves  []RValve
+	mindist int // shortest distance between two valves with a non-zero flowrate
+	minopen int // shortest time to open a valve with a non-zero flowrate
 }
 
+// a (partial) solution for one or more agents opening valves together
 type Solution struct {
 	pressure int
-	timeleft int
-	path     Path
-	is_open  []bool
-}
-
-type DuoSolution struct {
-	pressure int
-	timeleft [2]int
-	path     [2]Path
-	is_open  []bool
+	timeleft []int  // time left for each agent
+	path     []Path // path walked by each agent
+	is_open  Valveset
+	stopped  []int // time left for each agent when it stopped before its time was up, nil if none did
 }
 
-func parse_input(filename string) Vulcano {
-	inputstr, err := os.ReadFile(filename)
-	if err != nil {
-		panic(err)
-	}
-	inputlines := strings.Split(string(inputstr[:]), "\n")
-	// remove last empty line
-	if len(inputlines[len(inputlines)-1]) == 0 {
-		inputlines = inputlines[0 : len(inputlines)-1]
-	}
+// Besides the lines from the puzzle, this also reads tunnels that take longer to walk, and valves that take longer to open:
+// Valve BB has flow rate=13, opening takes 2 minutes; tunnels lead to valves CC (3), AA
+// Walking to CC takes 3 minutes, to AA 1 minute.
+func parse_input(r io.Reader) (Vulcano, error) {
+	scanner := bufio.NewScanner(r)
 	valves := make(map[string]Valve)
-	valveline_re := regexp.MustCompile(`^Valve (\w+) has flow rate=(\d+); tunnels? leads? to valves? (\w+(?:, \w+)*)$`)
-	for _, linestr := range inputlines {
-		match := valveline_re.FindStringSubmatch(linestr)
+	valveline_re := regexp.MustCompile(`^Valve (\w+) has flow rate=(\d+)(?:, opening takes (\d+) minutes?)?; tunnels? leads? to valves? (\w+(?: \(\d+\))?(?:, \w+(?: \(\d+\))?)*)$`)
+	tunnel_re := regexp.MustCompile(`(\w+)(?: \((\d+)\))?`)
+	for lnr := 0; scanner.Scan(); lnr++ {
+		linestr := scanner.Text()
+		match := valveline_re.FindStringSubmatchIndex(linestr)
 		if match == nil {
-			panic("Invalid input")
+			return Vulcano{}, &aoc.ParseError{Line: lnr + 1, Text: linestr, Msg: "not a valve description"}
 		}
-		name := match[1]
-		flowrate, err := strconv.Atoi(match[2])
+		name := linestr[match[2]:match[3]]
+		flowrate, err := strconv.Atoi(linestr[match[4]:match[5]])
 		if err != nil {
-			panic(err)
+			return Vulcano{}, &aoc.ParseError{Line: lnr + 1, Column: match[4] + 1, Text: linestr[match[4]:match[5]], Msg: "invalid flow rate", Err: err}
+		}
+		opentime := 1
+		if match[6] >= 0 {
+			opentime, err = strconv.Atoi(linestr[match[6]:match[7]])
+			if err != nil || opentime < 1 {
+				return Vulcano{}, &aoc.ParseError{Line: lnr + 1, Column: match[6] + 1, Text: linestr[match[6]:match[7]], Msg: "invalid opening time", Err: err}
+			}
+		}
+		var tunnels []Tunnel
+		for _, tmatch := range tunnel_re.FindAllStringSubmatchIndex(linestr[match[8]:match[9]], -1) {
+			tunnel := Tunnel{to: linestr[match[8]+tmatch[2] : match[8]+tmatch[3]], length: 1}
+			if tmatch[4] >= 0 {
+				start, end := match[8]+tmatch[4], match[8]+tmatch[5]
+				tunnel.length, err = strconv.Atoi(linestr[start:end])
+				if err != nil || tunnel.length < 1 {
+					return Vulcano{}, &aoc.ParseError{Line: lnr + 1, Column: start + 1, Text: linestr[start:end], Msg: "invalid tunnel length", Err: err}
+				}
+			}
+			tunnels = append(tunnels, tunnel)
 		}
-		tunnels := strings.Split(match[3], ", ")
 		valves[name] = Valve{
 			flowrate: flowrate,
+			opentime: opentime,
 			tunnel:   tunnels,
 		}
 	}
+	if err := scanner.Err(); err != nil {
+		return Vulcano{}, err
+	}
 	return Vulcano{
 		valves:  valves,
 		is_open: make(map[string]bool),
-	}
+	}, nil
 }
 
 type TreeWalker struct {
 	pos  string
 	dist int
+	seq  int // order in which the walkers were added, to walk in the same order when the distance is the same
+}
+
+// priority queue of walkers, with the shortest distance first
+type Walkers []TreeWalker
+
+func (w Walkers) Len() int { return len(w) }
+
+func (w Walkers) Less(i, j int) bool {
+	return w[i].dist < w[j].dist || (w[i].dist == w[j].dist && w[i].seq < w[j].seq)
+}
+
+func (w Walkers) Swap(i, j int) { w[i], w[j] = w[j], w[i] }
+
+func (w *Walkers) Push(x any) { *w = append(*w, x.(TreeWalker)) }
+
+func (w *Walkers) Pop() any {
+	old := *w
+	walker := old[len(old)-1]
+	*w = old[:len(old)-1]
+	return walker
+}
+
+// shortest distance from a valve to all valves that can be reached, using Dijkstra.
+// Also returns the valves in the order they were reached, and where the shortest way to each valve came from.
+func shortest_paths(name string, vl Vulcano) (map[string]int, []string, map[string]string) {
+	dist := map[string]int{name: 0}
+	came_from := make(map[string]string)
+	done := make(map[string]bool)
+	var order []string
+	walkers := &Walkers{{pos: name}}
+	for seq := 1; walkers.Len() > 0; {
+		w := heap.Pop(walkers).(TreeWalker)
+		if done[w.pos] {
+			// we've already been here, by a shorter way
+			continue
+		}
+		done[w.pos] = true
+		order = append(order, w.pos)
+		for _, tun := range vl.valves[w.pos].tunnel {
+			newdist := w.dist + tun.length
+			if olddist, seen := dist[tun.to]; seen && olddist <= newdist {
+				continue
+			}
+			dist[tun.to] = newdist
+			came_from[tun.to] = w.pos
+			// keep walking
+			heap.Push(walkers, TreeWalker{pos: tun.to, dist: newdist, seq: seq})
+			seq++
+		}
+	}
+	return dist, order, came_from
 }
 
 // map the tunnels in the original vulcano to the tunnels in the reduced vulcano.
 // valves that are not in the reduced vulcano do not appear, and are only represented in the distance between valves.
 // This actually maps distances to all other valves, independent of any intermediate valves
 func map_tunnels(name string, vl Vulcano, rv ReducedVulcano) []TunnelElem {
-	// walk the vulcano using simplified Dijkstra
-	var walkers []TreeWalker = []TreeWalker{{pos: name, dist: 0}}
-	seen := make(map[string]bool)
+	dist, order, _ := shortest_paths(name, vl)
 	result := make([]TunnelElem, 0, len(rv.valves))
-	seen[name] = true
-	for len(walkers) > 0 {
-		w := walkers[0]
-		walkers = walkers[1:]
-		newdist := w.dist + 1
-		for _, to := range vl.valves[w.pos].tunnel {
-			_, beenthere := seen[to]
-			if beenthere {
-				// we've already been here, skip
-				continue
-			}
-			seen[to] = true
-			rvalvenr, in_reduced := rv.valvenr[to]
-			if in_reduced {
-				// it is in the reduced tunnel valve, we found the distance
-				result = append(result, TunnelElem{newdist, rvalvenr})
-			}
-			// keep walking
-			walkers = append(walkers, TreeWalker{pos: to, dist: newdist})
+	for _, to := range order {
+		rvalvenr, in_reduced := rv.valvenr[to]
+		if in_reduced && to != name {
+			// it is in the reduced tunnel valve, we found the distance
+			result = append(result, TunnelElem{dist[to], rvalvenr})
 		}
 	}
 	return result
@@ -137,57 +230,26 @@ func reduce_vulcano(vl Vulcano, start string) ReducedVulcano {
 	for name, v := range vl.valves {
 		if v.flowrate != 0 || name == start {
 			rv.valvenr[name] = Valvenr(len(rv.valves))
-			rv.valves = append(rv.valves, RValve{name: name, flowrate: v.flowrate})
+			rv.valves = append(rv.valves, RValve{name: name, flowrate: v.flowrate, opentime: v.opentime})
+			if v.flowrate != 0 && (rv.minopen == 0 || v.opentime < rv.minopen) {
+				rv.minopen = v.opentime
+			}
 		}
 	}
 
 	// now map the tunnels between these valves
 	for vn, v := range rv.valves {
 		rv.valves[vn].tunnel = map_tunnels(v.name, vl, rv)
-	}
-	return rv
-}
-
-// Structure needed to sort valves with their distance
-type ValveDist struct {
-	flowrate, dist int
-}
-
-type ValveDists []ValveDist
-
-func (v ValveDists) Len() int { return len(v) }
-
-func (v ValveDists) Swap(i, j int) { v[i], v[j] = v[j], v[i] }
-
-func (v ValveDists) Less(i, j int) bool { return v[j].flowrate < v[i].flowrate }
-
-func max_extra_pressure(sol Solution, rv ReducedVulcano) int {
-	valvedists := make(ValveDists, 0, len(rv.valves))
-	pos := sol.path[len(sol.path)-1] &^ opened
-	// we can assume current position has tunnels to all relevant valves
-	for _, tun := range rv.valves[pos].tunnel {
-		if !sol.is_open[tun.valve] && rv.valves[tun.valve].flowrate > 0 {
-			valvedists = append(valvedists, ValveDist{flowrate: rv.valves[tun.valve].flowrate, dist: tun.dist})
-		}
-	}
-	sort.Sort(valvedists)
-
-	pressure := 0
-	// assume the theoretical optimal that each path to the best valve is this projected distance
-	timeleft := sol.timeleft
-	for _, vd := range valvedists {
-		// it takes 1 minute to open a valve
-		if timeleft <= vd.dist {
-			// just skip this valve if it is too far away
+		if v.flowrate == 0 {
 			continue
 		}
-		timeleft--
-		if timeleft <= 0 {
-			return pressure
+		for _, tun := range rv.valves[vn].tunnel {
+			if rv.valves[tun.valve].flowrate > 0 && (rv.mindist == 0 || tun.dist < rv.mindist) {
+				rv.mindist = tun.dist
+			}
 		}
-		pressure += vd.flowrate * (timeleft - vd.dist)
 	}
-	return pressure
+	return rv
 }
 
 // Structure needed to sort valves with their distance.
@@ -204,66 +266,78 @@ func (v ValveNrs) Swap(i, j int) { v[i], v[j] = v[j], v[i] }
 
 func (v ValveNrs) Less(i, j int) bool { return v[j].flowrate < v[i].flowrate }
 
-func max_extra_pressure2(sol DuoSolution, rv ReducedVulcano) int {
-	// sort the valves that need to be opened based on valvenr
+// Upper limit of the extra pressure the agents can still release. Takes the lowest of two optimistic estimates:
+// every closed valve is opened by the agent that can get there first, as if there are no other valves to open.
+// Or the largest valves are opened first, one after the other, at the shortest distance between any two valves.
+func max_extra_pressure(sol Solution, rv ReducedVulcano) int {
+	// sort the valves that need to be opened on flowrate
 	valvenrs := make(ValveNrs, 0, len(rv.valves))
 	for vnr, valve := range rv.valves {
-		if !sol.is_open[vnr] && valve.flowrate > 0 {
+		if !sol.is_open.has(Valvenr(vnr)) && valve.flowrate > 0 {
 			valvenrs = append(valvenrs, ValveNr{flowrate: valve.flowrate, valvenr: Valvenr(vnr)})
 		}
 	}
 	sort.Sort(valvenrs)
 
-	pressure := 0
-	timeleft := make([]int, 2)
-	copy(timeleft, sol.timeleft[:])
-	var pos [2]Valvenr
-	pos[0] = sol.path[0][len(sol.path[0])-1] &^ opened
-	pos[1] = sol.path[1][len(sol.path[1])-1] &^ opened
-	for _, vnr := range valvenrs {
-		// take the one which is closest. Or rather, with the most time left when going to the valve
-		which := -1
-		maxtimeleft := -1
-		for try := 0; try <= 1; try++ {
-			var this_dist int
-			if pos[try] == vnr.valvenr {
-				this_dist = 0
-			} else {
-				for _, tun := range rv.valves[pos[try]].tunnel {
-					if tun.valve == vnr.valvenr {
-						this_dist = tun.dist
-						break
-					}
-				}
+	// the time left after opening each valve by the agent that can get there first
+	maxtimeleft := make([]int, len(rv.valves))
+	// the moments at which the agents could open valves, at best
+	slots := make([]int, 0, 2*len(valvenrs))
+	dist := make([]int, len(rv.valves))
+	for agent, p := range sol.path {
+		pos := p[len(p)-1].valve
+		for i := range dist {
+			dist[i] = -1
+		}
+		dist[pos] = 0
+		for _, tun := range rv.valves[pos].tunnel {
+			dist[tun.valve] = tun.dist
+		}
+		mindist := -1
+		for _, vnr := range valvenrs {
+			d := dist[vnr.valvenr]
+			if d < 0 {
+				continue
 			}
-			if timeleft[try]-this_dist > maxtimeleft {
-				maxtimeleft = timeleft[try] - this_dist
-				which = try
+			if mindist < 0 || d < mindist {
+				mindist = d
+			}
+			if timeleft := sol.timeleft[agent] - d - rv.valves[vnr.valvenr].opentime; timeleft > maxtimeleft[vnr.valvenr] {
+				maxtimeleft[vnr.valvenr] = timeleft
 			}
 		}
-		if which == -1 || maxtimeleft <= 0 {
-			// nothing found in range, just skip this valve
+		if mindist < 0 {
 			continue
 		}
-		timeleft[which]--
-		if timeleft[which] <= 0 {
-			continue
+		for timeleft := sol.timeleft[agent] - mindist - rv.minopen; timeleft > 0; timeleft -= rv.mindist + rv.minopen {
+			slots = append(slots, timeleft)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(slots)))
+
+	per_valve := 0
+	by_size := 0
+	for i, vnr := range valvenrs {
+		per_valve += vnr.flowrate * maxtimeleft[vnr.valvenr]
+		if i < len(slots) {
+			by_size += vnr.flowrate * slots[i]
 		}
-		pressure += vnr.flowrate * (maxtimeleft - 1)
 	}
-	return pressure
+	if by_size < per_valve {
+		return by_size
+	}
+	return per_valve
 }
 
-func solution_str(rv ReducedVulcano, s Solution) string {
-	symbolicpath := make([]string, 0, len(s.path))
-	for i, p := range s.path {
-		valvenr := p &^ opened
-		if p&opened == opened {
-			symbolicpath = append(symbolicpath, fmt.Sprintf("Open-%s", rv.valves[valvenr].name))
+func path_str(rv ReducedVulcano, path Path) string {
+	symbolicpath := make([]string, 0, len(path))
+	for i, p := range path {
+		if p.open {
+			symbolicpath = append(symbolicpath, fmt.Sprintf("Open-%s", rv.valves[p.valve].name))
 		} else {
 			if i > 0 {
-				for _, tun := range rv.valves[s.path[i-1]&^opened].tunnel {
-					if tun.valve == p {
+				for _, tun := range rv.valves[path[i-1].valve].tunnel {
+					if tun.valve == p.valve {
 						if tun.dist > 1 {
 							symbolicpath = append(symbolicpath, fmt.Sprintf("(%d)", tun.dist-1))
 						}
@@ -271,159 +345,183 @@ func solution_str(rv ReducedVulcano, s Solution) string {
 					}
 				}
 			}
-			symbolicpath = append(symbolicpath, rv.valves[valvenr].name)
+			symbolicpath = append(symbolicpath, rv.valves[p.valve].name)
 		}
 	}
-	return fmt.Sprintf("pressure=%d, timeleft=%d, path=[%s]",
-		s.pressure, s.timeleft, strings.Join(symbolicpath, " "))
-}
-
-func solution_str2(rv ReducedVulcano, s DuoSolution) string {
-	var symbolicpaths [2][]string
-	symbolicpaths[0] = make([]string, 0, len(s.path[0]))
-	symbolicpaths[1] = make([]string, 0, len(s.path[1]))
-	for which, subp := range s.path {
-		for i, p := range subp {
-			valvenr := p &^ opened
-			if p&opened == opened {
-				symbolicpaths[which] = append(symbolicpaths[which], fmt.Sprintf("Open-%s", rv.valves[valvenr].name))
-			} else {
-				if i > 0 {
-					for _, tun := range rv.valves[subp[i-1]&^opened].tunnel {
-						if tun.valve == p {
-							if tun.dist > 1 {
-								symbolicpaths[which] = append(symbolicpaths[which], fmt.Sprintf("(%d)", tun.dist-1))
-							}
-							break
-						}
-					}
-				}
-				symbolicpaths[which] = append(symbolicpaths[which], rv.valves[valvenr].name)
-			}
-		}
+	return strings.Join(symbolicpath, " ")
+}
+
+func solution_str(rv ReducedVulcano, s Solution) string {
+	if len(s.path) == 1 {
+		return fmt.Sprintf("pressure=%d, timeleft=%d, path=[%s]", s.pressure, s.timeleft[0], path_str(rv, s.path[0]))
+	}
+	paths := make([]string, len(s.path))
+	for agent, p := range s.path {
+		paths[agent] = fmt.Sprintf("path%d=[%s] timeleft%d=%d", agent+1, path_str(rv, p), agent+1, s.timeleft[agent])
 	}
-	return fmt.Sprintf("pressure=%d, path1=[%s] path2=[%s] timeleft1=%d timeleft2=%d",
-		s.pressure, strings.Join(symbolicpaths[0], " "), strings.Join(symbolicpaths[1], " "), s.timeleft[0], s.timeleft[1])
+	return fmt.Sprintf("pressure=%d, %s", s.pressure, strings.Join(paths, " "))
 }
 
-func possible_next_steps(candidate Solution, rv ReducedVulcano, best *Solution) []Solution {
-	// if we cannot open more valves, this is a final solution
-	if candidate.timeleft <= 0 {
-		return nil
+// the solutions that follow from a candidate, and whether the candidate is a final solution.
+// Candidates that cannot reach min_pressure are not continued.
+func possible_next_steps(candidate Solution, rv ReducedVulcano, min_pressure int) ([]Solution, bool) {
+	// take the agent that has the most time left, and step that one
+	which := 0
+	for agent, timeleft := range candidate.timeleft {
+		if timeleft > candidate.timeleft[which] {
+			which = agent
+		}
+	}
+	// if we cannot open more valves, this is a final solution. Unless an agent stopped early and could still open one
+	// that nobody opened, then the route where it does is better and also counted.
+	if candidate.timeleft[which] <= 0 {
+		return nil, candidate.pressure >= min_pressure && !could_open_more(candidate, rv)
 	}
 	// calculate maximum pressure we could achieve by opening all remaining valves in order
 	max_possible := candidate.pressure + max_extra_pressure(candidate, rv)
-	if max_possible < best.pressure {
+	if max_possible < min_pressure {
 		// no point continuing with this solution
-		return nil
+		return nil, false
 	}
-	pos := candidate.path[len(candidate.path)-1] &^ opened
+	pos := candidate.path[which][len(candidate.path[which])-1].valve
 	valve := rv.valves[pos]
 	new_solutions := make([]Solution, 0, 10)
-	// a solution is opening this valve. Except if the flowrate is zero
-	if valve.flowrate > 0 && len(candidate.path) == 1 {
-		panic("Cannot handle starting at a non-zero valve")
-		// This is simply not implemented
+	// add a solution where the agent opens the valve "remote", after walking the steps to get there
+	add_solution := func(remote Valvenr, timeleft int, steps ...Step) {
+		// to prevent symmetric identical solutions, an agent is limited in the first step by what the agent before it did
+		if which > 0 && len(candidate.path[which]) == 1 && len(candidate.path[which-1]) > 1 && remote < candidate.path[which-1][1].valve {
+			return
+		}
+		new_path := make(Path, len(candidate.path[which]), len(candidate.path[which])+len(steps))
+		copy(new_path, candidate.path[which])
+		new_path = append(new_path, steps...)
+		new_solution := Solution{
+			pressure: candidate.pressure + rv.valves[remote].flowrate*timeleft,
+			timeleft: make([]int, len(candidate.timeleft)),
+			path:     make([]Path, len(candidate.path)),
+			is_open:  candidate.is_open.with(remote),
+			stopped:  candidate.stopped,
+		}
+		copy(new_solution.timeleft, candidate.timeleft)
+		copy(new_solution.path, candidate.path)
+		new_solution.timeleft[which] = timeleft
+		new_solution.path[which] = new_path
+		new_solutions = append(new_solutions, new_solution)
+	}
+	// when starting at a valve with a non-zero flowrate, opening it is the first option
+	if len(candidate.path[which]) == 1 && valve.flowrate > 0 && !candidate.is_open.has(pos) && candidate.timeleft[which] > valve.opentime {
+		add_solution(pos, candidate.timeleft[which]-valve.opentime, Step{valve: pos, open: true})
 	}
 	// try all tunnels from this position
 	for _, tunnel := range valve.tunnel {
 		remote := tunnel.valve
 		// no point going there unless we need to open this
-		if rv.valves[remote].flowrate > 0 && !candidate.is_open[remote] {
-			new_path := make(Path, len(candidate.path), len(candidate.path)+2)
-			copy(new_path, candidate.path)
-			// go there and open it
-			new_path = append(new_path, remote)
-			new_path = append(new_path, remote|opened)
-			new_open := make([]bool, len(candidate.is_open))
-			copy(new_open, candidate.is_open)
-			new_open[remote] = true
-			rvalve := rv.valves[remote]
-			new_solutions = append(new_solutions, Solution{
-				pressure: candidate.pressure + rvalve.flowrate*(candidate.timeleft-tunnel.dist-1),
-				timeleft: candidate.timeleft - tunnel.dist - 1,
-				path:     new_path,
-				is_open:  new_open,
-			})
+		if rv.valves[remote].flowrate == 0 || candidate.is_open.has(remote) {
+			continue
+		}
+		// and no point if there is no time left after opening it
+		timeleft := candidate.timeleft[which] - tunnel.dist - rv.valves[remote].opentime
+		if timeleft <= 0 {
+			continue
 		}
+		// go there and open it
+		add_solution(remote, timeleft, Step{valve: remote}, Step{valve: remote, open: true})
 	}
-	return new_solutions
+	// this agent can also stop, and leave the remaining valves to the others. Even when it could still open some,
+	// another agent might do that sooner. Stop the clock for this one.
+	others_busy := false
+	for agent, timeleft := range candidate.timeleft {
+		others_busy = others_busy || (agent != which && timeleft > 0)
+	}
+	if others_busy && (len(new_solutions) == 0 || len(candidate.path[which]) > 1) {
+		new_solution := candidate
+		new_solution.timeleft = make([]int, len(candidate.timeleft))
+		copy(new_solution.timeleft, candidate.timeleft)
+		new_solution.timeleft[which] = 0
+		new_solution.stopped = make([]int, len(candidate.timeleft))
+		copy(new_solution.stopped, candidate.stopped)
+		new_solution.stopped[which] = candidate.timeleft[which]
+		new_solutions = append(new_solutions, new_solution)
+	}
+	return new_solutions, len(new_solutions) == 0 && !could_open_more(candidate, rv)
 }
 
-func possible_next_steps2(candidate DuoSolution, rv ReducedVulcano, best *DuoSolution) []DuoSolution {
-	// take the one that has the most time left, and step that one
-	var which int
-	if candidate.timeleft[0] >= candidate.timeleft[1] {
-		which = 0
-	} else {
-		which = 1
+// whether an agent, also one that stopped early, has time to open a valve that is still closed
+func could_open_more(sol Solution, rv ReducedVulcano) bool {
+	for agent, timeleft := range sol.timeleft {
+		if sol.stopped != nil && sol.stopped[agent] > 0 {
+			timeleft = sol.stopped[agent]
+		}
+		pos := sol.path[agent][len(sol.path[agent])-1].valve
+		for _, tunnel := range rv.valves[pos].tunnel {
+			remote := rv.valves[tunnel.valve]
+			if remote.flowrate > 0 && !sol.is_open.has(tunnel.valve) && timeleft-tunnel.dist-remote.opentime > 0 {
+				return true
+			}
+		}
 	}
-	if candidate.timeleft[which] <= 0 {
-		return nil
+	return false
+}
+
+// find max flow using depth-first search, expaning on the best path first. The agents all start at the same valve.
+func findmaxflow(rv ReducedVulcano, start string, initial_timeleft int, agents int) Solution {
+	return findtopflows(rv, start, initial_timeleft, agents, 1, 1)[0]
+}
+
+// The best final solutions found so far, shared by all workers
+type TopSolutions struct {
+	top          int
+	min_pressure atomic.Int64 // a solution needs at least this pressure to get into the top
+	mu           sync.Mutex
+	solutions    []Solution // sorted by pressure, best first
+}
+
+// raise the minimum pressure, if another worker did not raise it further already
+func (ts *TopSolutions) raise(pressure int) {
+	for {
+		old := ts.min_pressure.Load()
+		if int64(pressure) <= old || ts.min_pressure.CompareAndSwap(old, int64(pressure)) {
+			return
+		}
 	}
-	// calculate maximum pressure we could achieve by opening all remaining valves in order
-	max_possible := candidate.pressure + max_extra_pressure2(candidate, rv)
-	if max_possible < best.pressure {
-		// no point continuing with this solution
-		return nil
+}
+
+// add a final solution
+func (ts *TopSolutions) add(sol Solution) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	pos := sort.Search(len(ts.solutions), func(i int) bool { return ts.solutions[i].pressure < sol.pressure })
+	ts.solutions = append(ts.solutions, Solution{})
+	copy(ts.solutions[pos+1:], ts.solutions[pos:])
+	ts.solutions[pos] = sol
+	if len(ts.solutions) > ts.top {
+		ts.solutions = ts.solutions[:ts.top]
 	}
-	pos := candidate.path[which][len(candidate.path[which])-1] &^ opened
-	valve := rv.valves[pos]
-	new_solutions := make([]DuoSolution, 0, 10)
-	// XXX again, opening current valve is not implemented
-	if valve.flowrate > 0 && len(candidate.path[which]) == 1 {
-		panic("Also not implemented")
+	if len(ts.solutions) == ts.top {
+		ts.raise(ts.solutions[ts.top-1].pressure)
 	}
-	for _, tunnel := range valve.tunnel {
-		remote := tunnel.valve
-		// to prevent symmetric identical solutions, walker #1 is limited in the first steps by what walker #0 does
-		if which == 1 && len(candidate.path[1]) == 1 && remote < candidate.path[0][1]&^opened {
-			continue
-		}
-		if rv.valves[remote].flowrate > 0 && !candidate.is_open[remote] {
-			new_path := make(Path, len(candidate.path[which]), len(candidate.path[which])+2)
-			copy(new_path, candidate.path[which])
-			// go there and open it
-			new_path = append(new_path, remote)
-			new_path = append(new_path, remote|opened)
-			new_open := make([]bool, len(candidate.is_open))
-			copy(new_open, candidate.is_open)
-			new_open[remote] = true
-			rvalve := rv.valves[remote]
-			var timeleft [2]int
-			timeleft[which] = candidate.timeleft[which] - tunnel.dist - 1
-			timeleft[1-which] = candidate.timeleft[1-which]
-			var paths [2]Path
-			paths[which] = new_path
-			paths[1-which] = candidate.path[1-which]
-			new_solutions = append(new_solutions, DuoSolution{
-				pressure: candidate.pressure + rvalve.flowrate*timeleft[which],
-				timeleft: timeleft,
-				path:     paths,
-				is_open:  new_open,
-			})
-		}
+}
+
+// a partial solution is as good as the final solution it leads to. With only the best solution in the top,
+// nothing worse is needed anymore. Otherwise the others must be at least as good as the worst in the top.
+func (ts *TopSolutions) partial(sol Solution) {
+	if ts.top == 1 {
+		ts.raise(sol.pressure)
 	}
-	return new_solutions
 }
 
-// find max flow using depth-first parallel search, expaning on the best path first
-func findmaxflow1(rv ReducedVulcano, start string, initial_timeleft int) (int, string) {
+// search everything that follows from a partial solution. Returns the maximum number of partial solutions held.
+func search(rv ReducedVulcano, initial Solution, ts *TopSolutions) int {
 	// collect all possible partial solutions here, sorted by pressure
 	partial_solutions := make([]Solution, 0, 20)
-	partial_solutions = append(partial_solutions, Solution{
-		pressure: 0,
-		timeleft: initial_timeleft,
-		path:     []Valvenr{rv.valvenr[start]},
-		is_open:  make([]bool, len(rv.valves)),
-	})
-	// whenever we have a better solution, store it in best_solution
-	best_solution := partial_solutions[0]
-	fmt.Printf("Starting with solution: %s\n", solution_str(rv, best_solution))
+	partial_solutions = append(partial_solutions, initial)
 	for len(partial_solutions) > 0 {
 		candidate := partial_solutions[len(partial_solutions)-1]
 		partial_solutions = partial_solutions[:len(partial_solutions)-1]
-		new_solutions := possible_next_steps(candidate, rv, &best_solution)
+		new_solutions, final := possible_next_steps(candidate, rv, int(ts.min_pressure.Load()))
+		if final {
+			ts.add(candidate)
+		}
 
 		// insert new solutions into partial solutions, in order
 		for _, news := range new_solutions {
@@ -440,68 +538,169 @@ func findmaxflow1(rv ReducedVulcano, start string, initial_timeleft int) (int, s
 			copy(partial_solutions[newpos+1:], partial_solutions[newpos:])
 			partial_solutions[newpos] = news
 		}
-		if len(partial_solutions) > 0 && partial_solutions[len(partial_solutions)-1].pressure > best_solution.pressure {
-			best_solution = partial_solutions[len(partial_solutions)-1]
+		if len(partial_solutions) > 0 {
+			ts.partial(partial_solutions[len(partial_solutions)-1])
 		}
 	}
-	fmt.Printf("max solutions held: %d\n", cap(partial_solutions))
-	return best_solution.pressure, solution_str(rv, best_solution)
+	return cap(partial_solutions)
 }
 
-func findmaxflow2(rv ReducedVulcano, start string, initial_timeleft int) (int, string) {
-	// collect possible partial solutions, sorted by pressure
-	partial_solutions := make([]DuoSolution, 0, 20)
-	partial_solutions = append(partial_solutions, DuoSolution{
+// find the top best solutions, in which no more valves can be opened. Best first.
+// With more than one worker, the first steps are divided over the workers, searching in parallel.
+func findtopflows(rv ReducedVulcano, start string, initial_timeleft int, agents int, top int, workers int) []Solution {
+	initial := Solution{
 		pressure: 0,
-		timeleft: [2]int{initial_timeleft, initial_timeleft},
-		path:     [2]Path{[]Valvenr{rv.valvenr[start]}, []Valvenr{rv.valvenr[start]}},
-		is_open:  make([]bool, len(rv.valves)),
-	})
-	// store the best solution here
-	best_solution := partial_solutions[0]
-	for len(partial_solutions) > 0 {
-		candidate := partial_solutions[len(partial_solutions)-1]
-		partial_solutions = partial_solutions[:len(partial_solutions)-1]
-		new_solutions := possible_next_steps2(candidate, rv, &best_solution)
-		// insert new solutions into partial solutions, in order
-		for _, news := range new_solutions {
-			newpos, _ := sort.Find(len(partial_solutions), func(i int) int {
-				if news.pressure < partial_solutions[i].pressure {
-					return -1
-				} else if news.pressure > partial_solutions[i].pressure {
-					return 1
-				} else {
-					return 0
-				}
-			})
-			partial_solutions = append(partial_solutions, DuoSolution{})
-			copy(partial_solutions[newpos+1:], partial_solutions[newpos:])
-			partial_solutions[newpos] = news
-		}
-		if len(partial_solutions) > 0 && partial_solutions[len(partial_solutions)-1].pressure > best_solution.pressure {
-			best_solution = partial_solutions[len(partial_solutions)-1]
+		timeleft: make([]int, agents),
+		path:     make([]Path, agents),
+		is_open:  new_valveset(len(rv.valves)),
+	}
+	for agent := range initial.path {
+		initial.timeleft[agent] = initial_timeleft
+		initial.path[agent] = Path{{valve: rv.valvenr[start]}}
+	}
+	ts := &TopSolutions{top: top, solutions: make([]Solution, 0, top+1)}
+	if workers <= 1 {
+		fmt.Printf("max solutions held: %d\n", search(rv, initial, ts))
+		return ts.solutions
+	}
+	first_steps, final := possible_next_steps(initial, rv, 0)
+	if final {
+		ts.add(initial)
+	}
+	// most promising first
+	sort.Slice(first_steps, func(i, j int) bool { return first_steps[i].pressure > first_steps[j].pressure })
+	todo := make(chan Solution, len(first_steps))
+	for _, step := range first_steps {
+		todo <- step
+	}
+	close(todo)
+	held := make([]int, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for step := range todo {
+				held[w] = max(held[w], search(rv, step, ts))
+			}
+		}(w)
+	}
+	wg.Wait()
+	fmt.Printf("max solutions held: %d in %d workers\n", slices.Max(held), workers)
+	return ts.solutions
+}
+
+// Solver solves the puzzle of day 16
+type Solver struct {
+	vulcano  Vulcano
+	rvulcano ReducedVulcano
+	start    string
+	minutes  int    // time available in part 1
+	minutes2 int    // time available in part 2
+	agents   int    // number of agents opening valves together in part 2
+	solver   string // "search" or "dp"
+	top      int    // number of best solutions to show
+	workers  int    // number of goroutines searching in parallel
+	export   string // prefix of the files to export the vulcano and the route to
+	replay   bool   // replay the best solution minute by minute
+}
+
+func New() *Solver {
+	return &Solver{start: "AA", minutes: 30, minutes2: 26, agents: 2, solver: "search", top: 1, workers: 1}
+}
+
+func (s *Solver) Flags(fs *flag.FlagSet) {
+	fs.StringVar(&s.start, "start", s.start, "valve where everyone starts")
+	fs.IntVar(&s.minutes, "minutes", s.minutes, "minutes available in part 1")
+	fs.IntVar(&s.minutes2, "minutes2", s.minutes2, "minutes available in part 2")
+	fs.IntVar(&s.agents, "agents", s.agents, "number of agents opening valves together in part 2")
+	fs.StringVar(&s.solver, "solver", s.solver, "how to find the best solution: search or dp")
+	fs.IntVar(&s.top, "top", s.top, "show this many of the best solutions")
+	fs.IntVar(&s.workers, "workers", s.workers, "number of workers searching in parallel, 0 for one per CPU")
+	fs.BoolVar(&s.replay, "replay", s.replay, "replay the best solution minute by minute, and check the pressure released")
+	fs.StringVar(&s.export, "export", s.export, "write the vulcano with the best route to `prefix`-part1.dot, -part1.json, -part2.dot and -part2.json")
+}
+
+func (s *Solver) Parse(r io.Reader) error {
+	if s.agents < 1 {
+		return fmt.Errorf("need at least 1 agent, not %d", s.agents)
+	}
+	if s.solver != "search" && s.solver != "dp" {
+		return fmt.Errorf("unknown solver %s, use search or dp", s.solver)
+	}
+	if s.top < 1 {
+		return fmt.Errorf("need at least the top 1, not %d", s.top)
+	}
+	if s.workers == 0 {
+		s.workers = runtime.NumCPU()
+	}
+	if s.workers < 0 {
+		return fmt.Errorf("need at least 1 worker, not %d", s.workers)
+	}
+	if s.top > 1 && s.solver != "search" {
+		return fmt.Errorf("the top %d solutions can only be found with the search solver", s.top)
+	}
+	vulcano, err := parse_input(r)
+	if err != nil {
+		return err
+	}
+	if len(vulcano.valves) > max_valves {
+		return fmt.Errorf("%d valves, can handle at most %d", len(vulcano.valves), max_valves)
+	}
+	if _, ok := vulcano.valves[s.start]; !ok {
+		return fmt.Errorf("start valve %s not found", s.start)
+	}
+	s.vulcano = vulcano
+	s.rvulcano = reduce_vulcano(vulcano, s.start)
+	if limit := dp_valve_limit(s.agents); s.solver == "dp" && dp_valves(s.rvulcano) > limit {
+		return fmt.Errorf("%d valves with a non-zero flow rate, the dp solver can handle at most %d with %d agents", dp_valves(s.rvulcano), limit, s.agents)
+	}
+	return nil
+}
+
+func (s *Solver) findmaxflow(timeleft int, agents int) Solution {
+	if s.solver == "dp" {
+		return findmaxflow_dp(s.rvulcano, s.start, timeleft, agents)
+	}
+	solutions := findtopflows(s.rvulcano, s.start, timeleft, agents, s.top, s.workers)
+	if s.top > 1 {
+		for rank, solution := range solutions {
+			fmt.Printf("top %d: %s\n", rank+1, solution_str(s.rvulcano, solution))
 		}
 	}
-	fmt.Printf("max solutions held: %d\n", cap(partial_solutions))
-	return best_solution.pressure, solution_str2(rv, best_solution)
-}
-
-func main() {
-	if len(os.Args) != 2 {
-		panic("Provide input file")
-	}
-	starttime := time.Now()
-	vulcano := parse_input(os.Args[1])
-	rvulcano := reduce_vulcano(vulcano, "AA")
-	parsetime := time.Now()
-	fmt.Printf("Got: %v\n", rvulcano)
-	fmt.Printf("Parse took: %s\n", parsetime.Sub(starttime))
-	flow, solution := findmaxflow1(rvulcano, "AA", 30)
-	part1time := time.Now()
-	fmt.Printf("maxflow pressure=%d: %s\n", flow, solution)
-	fmt.Printf("part1 took: %s\n", part1time.Sub(parsetime))
-	flow2, solution2 := findmaxflow2(rvulcano, "AA", 26)
-	part2time := time.Now()
-	fmt.Printf("maxflow with elephant pressure=%d: %s\n", flow2, solution2)
-	fmt.Printf("part2 took: %s\n", part2time.Sub(part1time))
+	return solutions[0]
+}
+
+func (s *Solver) Part1() any {
+	solution := s.findmaxflow(s.minutes, 1)
+	fmt.Printf("maxflow pressure=%d: %s\n", solution.pressure, solution_str(s.rvulcano, solution))
+	s.write_export("part1", solution)
+	s.run_replay(s.minutes, solution)
+	return solution.pressure
+}
+
+func (s *Solver) Part2() any {
+	solution := s.findmaxflow(s.minutes2, s.agents)
+	fmt.Printf("maxflow with %d agents pressure=%d: %s\n", s.agents, solution.pressure, solution_str(s.rvulcano, solution))
+	s.write_export("part2", solution)
+	s.run_replay(s.minutes2, solution)
+	return solution.pressure
+}
+
+func (s *Solver) run_replay(minutes int, solution Solution) {
+	if !s.replay {
+		return
+	}
+	if _, err := replay(os.Stdout, s.vulcano, s.rvulcano, s.start, minutes, solution); err != nil {
+		fmt.Fprintf(os.Stderr, "Replay failed: %s\n", err)
+	}
+}
+
+func (s *Solver) write_export(part string, solution Solution) {
+	if s.export == "" {
+		return
+	}
+	if err := write_export(s.export+"-"+part, make_export(s.vulcano, s.rvulcano, s.start, solution)); err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot export %s: %s\n", part, err)
+	}
 }
@@ -0,0 +1,267 @@
+package day16
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/jpcornet/AoC2022/aoc"
+	"github.com/jpcornet/AoC2022/aoc/aoctest"
+)
+
+func Test_parse_input(t *testing.T) {
+	vl, err := parse_input(strings.NewReader("Valve BB has flow rate=13, opening takes 2 minutes; tunnels lead to valves CC (3), AA\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bb := vl.valves["BB"]
+	if bb.flowrate != 13 || bb.opentime != 2 || len(bb.tunnel) != 2 || bb.tunnel[0] != (Tunnel{"CC", 3}) || bb.tunnel[1] != (Tunnel{"AA", 1}) {
+		t.Errorf("parse_input() valve BB = %+v", bb)
+	}
+}
+
+func Test_parse_input_errors(t *testing.T) {
+	tests := []struct {
+		name         string
+		input        string
+		line, column int
+		text         string
+	}{
+		{
+			name:   "no valve",
+			input:  "Valve AA has flow rate=0; tunnels lead to valves BB\nValve BB has flow rate=1\n",
+			line:   2,
+			column: 0,
+			text:   "Valve BB has flow rate=1",
+		},
+		{
+			name:   "zero opening time",
+			input:  "Valve AA has flow rate=0, opening takes 0 minutes; tunnels lead to valves BB\n",
+			line:   1,
+			column: 41,
+			text:   "0",
+		},
+		{
+			name:   "zero tunnel length",
+			input:  "Valve AA has flow rate=0; tunnels lead to valves BB, CC (0)\n",
+			line:   1,
+			column: 58,
+			text:   "0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parse_input(strings.NewReader(tt.input))
+			var perr *aoc.ParseError
+			if !errors.As(err, &perr) {
+				t.Fatalf("parse_input() error = %v, want a ParseError", err)
+			}
+			if perr.Line != tt.line || perr.Column != tt.column || perr.Text != tt.text {
+				t.Errorf("parse_input() error at %d:%d [%s], want %d:%d [%s]", perr.Line, perr.Column, perr.Text, tt.line, tt.column, tt.text)
+			}
+		})
+	}
+}
+
+func Test_findmaxflow(t *testing.T) {
+	tests := []struct {
+		name     string
+		start    string
+		minutes  int
+		agents   int
+		pressure int
+	}{
+		{name: "one agent", start: "AA", minutes: 30, agents: 1, pressure: 1651},
+		{name: "two agents", start: "AA", minutes: 26, agents: 2, pressure: 1707},
+		{name: "three agents", start: "AA", minutes: 22, agents: 3, pressure: 1470},
+		{name: "four agents", start: "AA", minutes: 22, agents: 4, pressure: 1501},
+		{name: "start at non-zero valve", start: "JJ", minutes: 30, agents: 1, pressure: 1807},
+		{name: "two agents at non-zero valve", start: "DD", minutes: 26, agents: 2, pressure: 1716},
+		{name: "three agents at non-zero valve", start: "BB", minutes: 22, agents: 3, pressure: 1425},
+	}
+	solvers := []struct {
+		name        string
+		findmaxflow func(rv ReducedVulcano, start string, timeleft int, agents int) Solution
+	}{
+		{"search", findmaxflow},
+		{"dp", findmaxflow_dp},
+		{"parallel", func(rv ReducedVulcano, start string, timeleft int, agents int) Solution {
+			return findtopflows(rv, start, timeleft, agents, 1, 4)[0]
+		}},
+	}
+	aoctest.Quiet(t)
+	for _, tt := range tests {
+		for _, solver := range solvers {
+			t.Run(tt.name+"/"+solver.name, func(t *testing.T) {
+				f, err := os.Open("input/sample.txt")
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer f.Close()
+				vl, err := parse_input(f)
+				if err != nil {
+					t.Fatal(err)
+				}
+				rv := reduce_vulcano(vl, tt.start)
+				sol := solver.findmaxflow(rv, tt.start, tt.minutes, tt.agents)
+				if sol.pressure != tt.pressure {
+					t.Errorf("findmaxflow() pressure = %d, want %d", sol.pressure, tt.pressure)
+				}
+				// walking the route through the tunnels should release the same pressure
+				if _, err := replay(io.Discard, vl, rv, tt.start, tt.minutes, sol); err != nil {
+					t.Errorf("replay() error = %v", err)
+				}
+			})
+		}
+	}
+}
+
+func Test_findtopflows(t *testing.T) {
+	f, err := os.Open("input/sample.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	vl, err := parse_input(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aoctest.Quiet(t)
+	want := []int{1651, 1650, 1649, 1648, 1648, 1647, 1647, 1646}
+	for _, workers := range []int{1, 4} {
+		solutions := findtopflows(reduce_vulcano(vl, "AA"), "AA", 30, 1, len(want), workers)
+		if len(solutions) != len(want) {
+			t.Fatalf("findtopflows() with %d workers found %d solutions, want %d", workers, len(solutions), len(want))
+		}
+		for i, sol := range solutions {
+			if sol.pressure != want[i] {
+				t.Errorf("findtopflows() with %d workers solution %d pressure = %d, want %d", workers, i+1, sol.pressure, want[i])
+			}
+		}
+	}
+}
+
+func Test_make_export(t *testing.T) {
+	f, err := os.Open("input/sample.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	vl, err := parse_input(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aoctest.Quiet(t)
+	rv := reduce_vulcano(vl, "AA")
+	exp := make_export(vl, rv, "AA", findmaxflow(rv, "AA", 30, 1))
+	if len(exp.Valves) != 10 || len(exp.Distances) != 7*6/2 || len(exp.Routes) != 1 {
+		t.Fatalf("make_export() has %d valves, %d distances and %d routes, want 10, 21 and 1", len(exp.Valves), len(exp.Distances), len(exp.Routes))
+	}
+	walk := strings.Join(exp.Routes[0].Walk, " ")
+	if want := "AA DD CC BB AA II JJ II AA DD EE FF GG HH GG FF EE DD CC"; walk != want {
+		t.Errorf("make_export() walk = %s, want %s", walk, want)
+	}
+	opened := strings.Join(exp.Routes[0].Opened, " ")
+	if want := "DD BB JJ HH EE CC"; opened != want {
+		t.Errorf("make_export() opened = %s, want %s", opened, want)
+	}
+}
+
+// with several agents, an agent can stop and leave valves to the others. Routes where it stopped while it could
+// still open a valve that nobody opened are not in the top.
+func Test_findtopflows_complete(t *testing.T) {
+	f, err := os.Open("input/sample.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	vl, err := parse_input(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rv := reduce_vulcano(vl, "AA")
+	aoctest.Quiet(t)
+	for _, agents := range []int{2, 3} {
+		for minutes := 6; minutes <= 26; minutes += 2 {
+			for rank, sol := range findtopflows(rv, "AA", minutes, agents, 20, 1) {
+				for agent, path := range sol.path {
+					timeleft := minutes - len(actions(vl, rv, "AA", path))
+					pos := path[len(path)-1].valve
+					for _, tunnel := range rv.valves[pos].tunnel {
+						remote := rv.valves[tunnel.valve]
+						if remote.flowrate > 0 && !sol.is_open.has(tunnel.valve) && timeleft-tunnel.dist-remote.opentime > 0 {
+							t.Errorf("%d agents in %d minutes, top %d: agent %d can still open %s: %s", agents, minutes, rank+1, agent+1, remote.name, solution_str(rv, sol))
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// more valves than fit in a byte: a long corridor, with the flow rates going up to the end where we start
+func Test_many_valves(t *testing.T) {
+	var input strings.Builder
+	for i := 0; i < 300; i++ {
+		flowrate := i
+		if i == 299 {
+			flowrate = 0
+		}
+		tunnels := []string{}
+		if i > 0 {
+			tunnels = append(tunnels, fmt.Sprintf("V%03d", i-1))
+		}
+		if i < 299 {
+			tunnels = append(tunnels, fmt.Sprintf("V%03d", i+1))
+		}
+		fmt.Fprintf(&input, "Valve V%03d has flow rate=%d; tunnels lead to valves %s\n", i, flowrate, strings.Join(tunnels, ", "))
+	}
+	vl, err := parse_input(strings.NewReader(input.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	aoctest.Quiet(t)
+	// open V298 with 4 minutes left, and V297 with 2 minutes left
+	sol := findmaxflow(reduce_vulcano(vl, "V299"), "V299", 6, 1)
+	if sol.pressure != 298*4+297*2 {
+		t.Errorf("findmaxflow() pressure = %d, want %d", sol.pressure, 298*4+297*2)
+	}
+}
+
+// the dp solver can handle fewer valves when there are more than 2 agents
+func Test_dp_limit(t *testing.T) {
+	var input strings.Builder
+	for i := 0; i < 20; i++ {
+		fmt.Fprintf(&input, "Valve V%02d has flow rate=%d; tunnels lead to valves V%02d, V%02d\n", i, i, (i+19)%20, (i+1)%20)
+	}
+	tests := []struct {
+		agents  int
+		wantErr bool
+	}{
+		{agents: 1},
+		{agents: 2},
+		{agents: 3, wantErr: true},
+	}
+	for _, tt := range tests {
+		s := New()
+		s.solver, s.agents, s.start = "dp", tt.agents, "V00"
+		if err := s.Parse(strings.NewReader(input.String())); (err != nil) != tt.wantErr {
+			t.Errorf("Parse() with %d agents error = %v, wantErr %v", tt.agents, err, tt.wantErr)
+		}
+	}
+}
+
+func BenchmarkSolver(b *testing.B) {
+	aoctest.Benchmark(b, func() aoc.Solver { return New() }, aoctest.BenchInput("input"))
+}
+
+func BenchmarkSolverDP(b *testing.B) {
+	aoctest.Benchmark(b, func() aoc.Solver {
+		s := New()
+		s.solver = "dp"
+		return s
+	}, aoctest.BenchInput("input"))
+}
@@ -0,0 +1,179 @@
+package day17
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Show the rocks falling in the terminal, or draw the whole tower as an image, to see the pruning and the cycles.
+
+// An Animation draws every step of a falling rock in the terminal
+type Animation struct {
+	w     io.Writer
+	delay time.Duration // time between two steps
+	rows  int           // number of lines of the chamber shown
+}
+
+// the part of the chamber around the falling rock, drawn like in the puzzle
+func (c *Chamber) frame(rock Rock, x, y int, rows int) string {
+	top := max(y+rock.y, c.height())
+	bottom := max(top-rows, 0)
+	var sb strings.Builder
+	for row := top - 1; row >= bottom; row-- {
+		var falling Line
+		if row >= y && row < y+rock.y {
+			falling = rock.lines[row-y] << (c.width - rock.x - x)
+		}
+		var stack Line
+		if row >= c.stack.offset && row < c.height() {
+			stack = c.stack.lines[row-c.stack.offset]
+		}
+		sb.WriteByte('|')
+		for col := c.width - 1; col >= 0; col-- {
+			switch {
+			case falling&(1<<col) != 0:
+				sb.WriteByte('@')
+			case row < c.stack.offset:
+				sb.WriteByte('~') // pruned
+			case stack&(1<<col) != 0:
+				sb.WriteByte('#')
+			default:
+				sb.WriteByte('.')
+			}
+		}
+		sb.WriteString("|\n")
+	}
+	if bottom == 0 {
+		fmt.Fprintf(&sb, "+%s+\n", strings.Repeat("-", c.width))
+	} else {
+		fmt.Fprintf(&sb, "(..%d..)\n", bottom)
+	}
+	return sb.String()
+}
+
+// clear the terminal and draw the falling rock, which is rock rocknr of the list. The jet shown pushes it next.
+func (c *Chamber) animate(rocknr int, rock Rock, x, y int) {
+	a := c.animation
+	fmt.Fprintf(a.w, "\033[H\033[2J%sheight %d, rock %d, next jet %d\n", c.frame(rock, x, y, a.rows), c.height(), rocknr, c.streamnr)
+	time.Sleep(a.delay)
+}
+
+// A Tower remembers every rock that was dropped, also the ones that were pruned from the stack
+type Tower struct {
+	width   int
+	rows    [][]uint8 // for every cell, 0 for air or 1 + the number of the rock
+	heights []int     // height after each number of rocks
+	pruned  []int     // heights where the stack was pruned
+}
+
+func new_tower(width int) *Tower {
+	return &Tower{width: width, heights: []int{0}}
+}
+
+func (t *Tower) add(c *Chamber, rocknr int, rock Rock, x, y int, pruned bool) {
+	for ry, l := range rock.lines {
+		for y+ry >= len(t.rows) {
+			t.rows = append(t.rows, make([]uint8, t.width))
+		}
+		l <<= c.width - rock.x - x
+		for col := 0; col < c.width; col++ {
+			if l&(1<<col) != 0 {
+				t.rows[y+ry][c.width-1-col] = uint8(rocknr + 1)
+			}
+		}
+	}
+	t.heights = append(t.heights, c.height())
+	if pruned {
+		t.pruned = append(t.pruned, c.stack.offset)
+	}
+}
+
+// colors of the rocks, the walls and the markers
+var (
+	rock_colors  = []color.RGBA{{230, 80, 60, 255}, {240, 170, 40, 255}, {90, 180, 70, 255}, {60, 130, 220, 255}, {160, 90, 200, 255}}
+	wall_color   = color.RGBA{100, 100, 100, 255}
+	air_color    = color.RGBA{20, 20, 30, 255}
+	pruned_color = color.RGBA{255, 0, 0, 255}
+	cycle_color  = color.RGBA{0, 200, 255, 255}
+)
+
+// pixels for each cell
+const cell_size = 3
+
+// Draw the tower, with the floor at the bottom. Left of the chamber the rows where the stack was pruned are marked,
+// right of it the start of every repeat of the cycle.
+func (t *Tower) image(cycle Cycle) *image.RGBA {
+	// marker, wall, chamber, wall, marker
+	cols := t.width + 4
+	rows := len(t.rows) + 1
+	img := image.NewRGBA(image.Rect(0, 0, cols*cell_size, rows*cell_size))
+	cell := func(col, row int, c color.RGBA) {
+		// row 0 is the floor, at the bottom of the image
+		for py := 0; py < cell_size; py++ {
+			for px := 0; px < cell_size; px++ {
+				img.SetRGBA(col*cell_size+px, (rows-1-row)*cell_size+py, c)
+			}
+		}
+	}
+	for row := 0; row < rows; row++ {
+		cell(1, row, wall_color)
+		cell(cols-2, row, wall_color)
+	}
+	for col := 1; col < cols-1; col++ {
+		cell(col, 0, wall_color)
+	}
+	for y, line := range t.rows {
+		for x, rocknr := range line {
+			c := air_color
+			if rocknr > 0 {
+				c = rock_colors[int(rocknr-1)%len(rock_colors)]
+			}
+			cell(x+2, y+1, c)
+		}
+	}
+	for _, height := range t.pruned {
+		cell(0, height+1, pruned_color)
+	}
+	if cycle.length > 0 {
+		for n := cycle.start; n < len(t.heights); n += cycle.length {
+			cell(cols-1, t.heights[n], cycle_color)
+		}
+	}
+	return img
+}
+
+// drop the rocks, drawing the chamber after every step
+func (s *Solver) animate_rocks(rocks int) {
+	c := new_chamber(s.width, s.rocks, s.stream)
+	c.animation = &Animation{w: os.Stdout, delay: s.delay, rows: s.rows}
+	for i := 0; i < rocks; i++ {
+		c.drop_one_rock()
+	}
+}
+
+// drop the rocks and write the tower as a PNG image
+func (s *Solver) write_png(file string, rocks int) error {
+	c := new_chamber(s.width, s.rocks, s.stream)
+	c.tower = new_tower(s.width)
+	for i := 0; i < rocks; i++ {
+		c.drop_one_rock()
+	}
+	// find the cycle in another simulation, that one skips ahead
+	cc := new_chamber(s.width, s.rocks, s.stream)
+	cc.simulate(rocks)
+	fh, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(fh, c.tower.image(cc.cycle)); err != nil {
+		fh.Close()
+		return err
+	}
+	return fh.Close()
+}
@@ -0,0 +1,153 @@
+package day17
+
+import (
+	"encoding/gob"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+)
+
+// Save the state of a long simulation to a file, to continue it later.
+
+type SnapshotRock struct {
+	X, Y  int
+	Lines []Line
+}
+
+type SnapshotRepeat struct {
+	Rocknr, Streamnr int
+	Stack            [snaplen]Line
+	Rocks, Height    int
+}
+
+type SnapshotSurface struct {
+	Streamnr      int
+	Profile       []uint8 // only the columns of the chamber
+	Rocks, Height int
+}
+
+// A Snapshot has everything of a Chamber that is needed to continue the simulation. That is only the last part of the
+// history, and the positions seen in it, so the snapshot does not grow with the number of rocks.
+type Snapshot struct {
+	// what is simulated, to check that the simulation continues with the same input
+	Width  int
+	Rocks  []SnapshotRock
+	Stream string
+	// how far it got
+	Rocknr, Streamnr int
+	Offset           int
+	Lines            []Line
+	TotalRocks       int
+	HistoryStart     int
+	History          []int
+	Repeats          []SnapshotRepeat
+	Surfaces         []SnapshotSurface
+}
+
+func (c *Chamber) snapshot() Snapshot {
+	snap := Snapshot{
+		Width:        c.width,
+		Stream:       c.stream,
+		Rocknr:       c.rocknr,
+		Streamnr:     c.streamnr,
+		Offset:       c.stack.offset,
+		Lines:        c.stack.lines,
+		TotalRocks:   c.total_rocks,
+		HistoryStart: c.history_start,
+		History:      c.history,
+	}
+	for _, rock := range c.rocks {
+		snap.Rocks = append(snap.Rocks, SnapshotRock{X: rock.x, Y: rock.y, Lines: rock.lines})
+	}
+	for pos, progress := range c.game_repeat {
+		snap.Repeats = append(snap.Repeats, SnapshotRepeat{Rocknr: pos.rocknr, Streamnr: pos.streamnr, Stack: pos.stacksnap, Rocks: progress.num_rocks, Height: progress.stack_size})
+	}
+	for pos, progress := range c.surface_repeat {
+		snap.Surfaces = append(snap.Surfaces, SnapshotSurface{Streamnr: pos.streamnr, Profile: slices.Clone(pos.profile[:c.width]), Rocks: progress.num_rocks, Height: progress.stack_size})
+	}
+	return snap
+}
+
+// continue the simulation from a snapshot, which must be of the same chamber, rocks and stream
+func (c *Chamber) restore(snap Snapshot) error {
+	same_rocks := len(snap.Rocks) == len(c.rocks)
+	for i := 0; same_rocks && i < len(c.rocks); i++ {
+		rock := c.rocks[i]
+		same_rocks = snap.Rocks[i].X == rock.x && snap.Rocks[i].Y == rock.y && slices.Equal(snap.Rocks[i].Lines, rock.lines)
+	}
+	if snap.Width != c.width || !same_rocks || snap.Stream != c.stream {
+		return fmt.Errorf("snapshot is of another simulation")
+	}
+	if snap.Rocknr >= len(c.rocks) || snap.Streamnr >= len(c.stream) || snap.HistoryStart < 0 || len(snap.History) != snap.TotalRocks-snap.HistoryStart+1 {
+		return fmt.Errorf("snapshot is not valid")
+	}
+	c.rocknr, c.streamnr = snap.Rocknr, snap.Streamnr
+	// the simulation changes these, the snapshot can be restored again
+	c.stack = Stack{offset: snap.Offset, lines: slices.Clone(snap.Lines)}
+	c.total_rocks = snap.TotalRocks
+	c.history_start, c.history = snap.HistoryStart, slices.Clone(snap.History)
+	c.game_repeat = make(MemRepeat)
+	for _, r := range snap.Repeats {
+		c.game_repeat[GamePos{rocknr: r.Rocknr, streamnr: r.Streamnr, stacksnap: r.Stack}] = GameProgress{num_rocks: r.Rocks, stack_size: r.Height}
+	}
+	c.surface_repeat = make(MemSurface)
+	for _, s := range snap.Surfaces {
+		pos := SurfacePos{streamnr: s.Streamnr}
+		copy(pos.profile[:c.width], s.Profile)
+		c.surface_repeat[pos] = GameProgress{num_rocks: s.Rocks, stack_size: s.Height}
+	}
+	return nil
+}
+
+// write the snapshot to a new file first, so there is always a complete snapshot
+func (snap Snapshot) write(file string) error {
+	fh, err := os.Create(file + ".new")
+	if err != nil {
+		return err
+	}
+	if err := gob.NewEncoder(fh).Encode(snap); err != nil {
+		fh.Close()
+		return err
+	}
+	if err := fh.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file+".new", file)
+}
+
+func read_snapshot(file string) (Snapshot, error) {
+	var snap Snapshot
+	fh, err := os.Open(file)
+	if err != nil {
+		return snap, err
+	}
+	defer fh.Close()
+	if err := gob.NewDecoder(fh).Decode(&snap); err != nil {
+		return snap, fmt.Errorf("reading snapshot %s: %w", file, err)
+	}
+	return snap, nil
+}
+
+// AutoSave saves a snapshot every so often while simulating
+type AutoSave struct {
+	file  string
+	every time.Duration
+	next  time.Time
+}
+
+// only look at the clock every so many rocks
+const autosave_rocks = 1 << 12
+
+func (a *AutoSave) check(c *Chamber) {
+	if c.total_rocks%autosave_rocks != 0 || time.Now().Before(a.next) {
+		return
+	}
+	if err := c.snapshot().write(a.file); err != nil {
+		fmt.Fprintf(os.Stderr, "cannot save snapshot, not trying again: %v\n", err)
+		c.autosave = nil
+		return
+	}
+	fmt.Printf("saved snapshot after %d rocks, height %d\n", c.total_rocks, c.height())
+	a.next = time.Now().Add(a.every)
+}
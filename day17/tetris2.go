@@ -1,16 +1,23 @@
-package main
+package day17
 
 import (
+	"bufio"
+	"flag"
 	"fmt"
+	"io"
+	"math/bits"
 	"os"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/jpcornet/AoC2022/aoc"
 )
 
-// each bit is one rock, bit 6 is leftmost, bit 0 is rightmost. 0 = air, 1 = rock
-type Line uint8
+// each bit is one rock, bit width-1 is leftmost, bit 0 is rightmost. 0 = air, 1 = rock
+type Line uint64
 
-const width = 7
+const max_width = 64
 
 // if the stack gets this big, there is something wrong with the pruning
 const max_stack = 1_000_000
@@ -20,61 +27,167 @@ type Stack struct {
 	lines  []Line
 }
 
+// a rock is x wide and y high. In the lines, bit 0 is the rightmost part of the rock
 type Rock struct {
 	x, y  int
 	lines []Line
 }
 
-var rocks []Rock
+// the rocks from the puzzle
+var default_rocks = []Rock{
+	{x: 4, y: 1, lines: []Line{0xf}},
+	{x: 3, y: 3, lines: []Line{0x2, 0x7, 0x2}},
+	{x: 3, y: 3, lines: []Line{0x7, 0x1, 0x1}}, // first row is bottom row of rock
+	{x: 1, y: 4, lines: []Line{0x1, 0x1, 0x1, 0x1}},
+	{x: 2, y: 2, lines: []Line{0x3, 0x3}},
+}
+
+// Read rocks drawn with # and ., separated by empty lines. Before the rocks, the width of the chamber can be
+// given on a line like "width 9". Returns 0 as the width if it is not given.
+func parse_rocks(r io.Reader) ([]Rock, int, error) {
+	scanner := bufio.NewScanner(r)
+	var result []Rock
+	// the lines of the rock being read, top row first
+	var drawing []string
+	chamber_width := 0
+	add_rock := func(lnr int) error {
+		if len(drawing) == 0 {
+			return nil
+		}
+		rock := Rock{y: len(drawing)}
+		for _, row := range drawing {
+			rock.x = max(rock.x, len(row))
+		}
+		if rock.x > max_width {
+			return &aoc.ParseError{Line: lnr, Text: drawing[len(drawing)-1], Msg: fmt.Sprintf("rock is wider than %d", max_width)}
+		}
+		empty := true
+		for i := len(drawing) - 1; i >= 0; i-- {
+			var l Line
+			for x, c := range drawing[i] {
+				if c == '#' {
+					l |= 1 << (rock.x - 1 - x)
+				}
+			}
+			empty = empty && l == 0
+			rock.lines = append(rock.lines, l)
+		}
+		if empty {
+			return &aoc.ParseError{Line: lnr, Text: drawing[len(drawing)-1], Msg: "rock without any #"}
+		}
+		result = append(result, rock)
+		drawing = nil
+		return nil
+	}
+	for lnr := 0; scanner.Scan(); lnr++ {
+		linestr := strings.TrimRight(scanner.Text(), " \t\r")
+		if strings.HasPrefix(linestr, "width ") {
+			if len(result) > 0 || len(drawing) > 0 {
+				return nil, 0, &aoc.ParseError{Line: lnr + 1, Text: linestr, Msg: "width must be given before the rocks"}
+			}
+			w, err := strconv.Atoi(linestr[6:])
+			if err != nil || w < 1 || w > max_width {
+				return nil, 0, &aoc.ParseError{Line: lnr + 1, Column: 7, Text: linestr[6:], Msg: fmt.Sprintf("width must be 1 to %d", max_width), Err: err}
+			}
+			chamber_width = w
+			continue
+		}
+		if linestr == "" {
+			if err := add_rock(lnr); err != nil {
+				return nil, 0, err
+			}
+			continue
+		}
+		if i := strings.IndexFunc(linestr, func(c rune) bool { return c != '#' && c != '.' }); i >= 0 {
+			return nil, 0, &aoc.ParseError{Line: lnr + 1, Column: i + 1, Text: linestr, Msg: "rocks are drawn with # and ."}
+		}
+		drawing = append(drawing, linestr)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, err
+	}
+	if err := add_rock(0); err != nil {
+		return nil, 0, err
+	}
+	if len(result) == 0 {
+		return nil, 0, &aoc.ParseError{Line: 1, Msg: "no rocks"}
+	}
+	return result, chamber_width, nil
+}
+
+// A Chamber is one simulation of rocks falling, pushed around by the jets of hot gas
+type Chamber struct {
+	width    int
+	rocks    []Rock // the rocks that fall, in order
+	stream   string // the jets of hot gas
+	rocknr   int    // the next rock to fall
+	streamnr int    // the next jet
+	stack    Stack
+	// the progress of the simulation
+	total_rocks    int
+	history_start  int   // number of rocks of the first height in history
+	history        []int // the height of the stack after each number of rocks, from history_start on
+	game_repeat    MemRepeat
+	surface_repeat MemSurface
+	max_cycle      int   // only look for cycles up to this many rocks, that bounds what is remembered
+	cycle          Cycle // the repeat found by the last simulation
 
-var rocknr, streamnr int
-var stack Stack
-var stream string
+	animation *Animation // if set, draw every step
+	tower     *Tower     // if set, remember every rock
+	autosave  *AutoSave  // if set, save snapshots while simulating
+}
 
-func setup_field() {
-	rocks = []Rock{
-		{x: 4, y: 1, lines: []Line{0x78}},
-		{x: 3, y: 3, lines: []Line{0x20, 0x70, 0x20}},
-		{x: 3, y: 3, lines: []Line{0x70, 0x10, 0x10}}, // first row is bottom row of rock
-		{x: 1, y: 4, lines: []Line{0x40, 0x40, 0x40, 0x40}},
-		{x: 2, y: 2, lines: []Line{0x60, 0x60}},
+func new_chamber(width int, rocks []Rock, stream string) *Chamber {
+	return &Chamber{
+		width:          width,
+		rocks:          rocks,
+		stream:         stream,
+		stack:          Stack{lines: make([]Line, 0, 10000)},
+		history:        []int{0},
+		game_repeat:    make(MemRepeat),
+		surface_repeat: make(MemSurface),
+		max_cycle:      default_max_cycle,
 	}
-	stack.lines = make([]Line, 0, 10000)
-	rocknr = 0
-	streamnr = 0
 }
 
-func next_rock() Rock {
-	result := rocks[rocknr]
-	rocknr++
-	if rocknr == len(rocks) {
-		rocknr = 0
+// all bits of a line set, a horizontal bar the width of the chamber
+func (c *Chamber) full_line() Line {
+	return ^Line(0) >> (max_width - c.width)
+}
+
+// height of the stack of rocks
+func (c *Chamber) height() int {
+	return len(c.stack.lines) + c.stack.offset
+}
+
+func (c *Chamber) next_rock() Rock {
+	result := c.rocks[c.rocknr]
+	c.rocknr++
+	if c.rocknr == len(c.rocks) {
+		c.rocknr = 0
 	}
 	return result
 }
 
-func next_stream() int {
-	schar := stream[streamnr]
-	streamnr++
-	if streamnr == len(stream) {
-		streamnr = 0
+func (c *Chamber) next_stream() int {
+	schar := c.stream[c.streamnr]
+	c.streamnr++
+	if c.streamnr == len(c.stream) {
+		c.streamnr = 0
 	}
-	switch schar {
-	case '<':
+	// the stream only has < and >, checked by parse_stream
+	if schar == '<' {
 		return -1
-	case '>':
-		return 1
-	default:
-		panic(fmt.Sprintf("Invalid stream char [%c] in input", schar))
 	}
+	return 1
 }
 
-func has_overlap(rock Rock, x, y int) bool {
+func (c *Chamber) has_overlap(rock Rock, x, y int) bool {
 	for ry, l := range rock.lines {
-		if y+ry >= len(stack.lines)+stack.offset {
+		if y+ry >= c.height() {
 			return false
 		}
-		if stack.lines[y+ry-stack.offset]&(l>>x) != 0 {
+		if c.stack.lines[y+ry-c.stack.offset]&(l<<(c.width-rock.x-x)) != 0 {
 			return true
 		}
 	}
@@ -82,49 +195,57 @@ func has_overlap(rock Rock, x, y int) bool {
 }
 
 // adds rock to the stack. Returns true if the stack has just been pruned.
-func add_rock_to_stack(rock Rock, x, y int) bool {
-	hbar := Line((1 << width) - 1)
+func (c *Chamber) add_rock_to_stack(rock Rock, x, y int) bool {
+	hbar := c.full_line()
 	barfound := -1
 	for ry, l := range rock.lines {
-		for y+ry >= len(stack.lines)+stack.offset {
-			stack.lines = append(stack.lines, Line(0))
+		for y+ry >= c.height() {
+			c.stack.lines = append(c.stack.lines, Line(0))
 		}
-		stack.lines[y+ry-stack.offset] |= l >> x
-		if stack.lines[y+ry-stack.offset] == hbar {
-			barfound = y + ry - stack.offset
+		c.stack.lines[y+ry-c.stack.offset] |= l << (c.width - rock.x - x)
+		if c.stack.lines[y+ry-c.stack.offset] == hbar {
+			barfound = y + ry - c.stack.offset
 		}
 	}
 	if barfound != -1 {
-		stack.lines = stack.lines[barfound:]
-		stack.offset += barfound
+		c.stack.lines = c.stack.lines[barfound:]
+		c.stack.offset += barfound
 		return true
 	} else {
 		// find two adjacent lines that together form an hbar. Which is also impenetrable for any rock with size > 1
-		for dy := len(stack.lines) - 2; dy >= y-stack.offset; dy-- {
-			if (stack.lines[dy] | stack.lines[dy+1]) == hbar {
-				stack.lines = stack.lines[dy:]
-				stack.offset += dy
+		for dy := len(c.stack.lines) - 2; dy >= y-c.stack.offset; dy-- {
+			if (c.stack.lines[dy] | c.stack.lines[dy+1]) == hbar {
+				c.stack.lines = c.stack.lines[dy:]
+				c.stack.offset += dy
 				return true
 			}
 		}
 	}
-	if len(stack.lines) > max_stack {
+	if len(c.stack.lines) > max_stack {
 		panic("Stack grew too big, something wrong with pruning")
 	}
 	return false
 }
 
-func drop_one_rock() bool {
-	rock := next_rock()
+func (c *Chamber) drop_one_rock() bool {
+	rocknr := c.rocknr
+	rock := c.next_rock()
 	x := 2
-	y := len(stack.lines) + stack.offset + 3
+	y := c.height() + 3
 	for true {
-		dx := next_stream()
-		if x+dx >= 0 && x+dx+rock.x-1 < width && !has_overlap(rock, x+dx, y) {
+		if c.animation != nil {
+			c.animate(rocknr, rock, x, y)
+		}
+		dx := c.next_stream()
+		if x+dx >= 0 && x+dx+rock.x-1 < c.width && !c.has_overlap(rock, x+dx, y) {
 			x += dx
 		}
-		if y == 0 || has_overlap(rock, x, y-1) {
-			return add_rock_to_stack(rock, x, y)
+		if y == 0 || c.has_overlap(rock, x, y-1) {
+			pruned := c.add_rock_to_stack(rock, x, y)
+			if c.tower != nil {
+				c.tower.add(c, rocknr, rock, x, y, pruned)
+			}
+			return pruned
 		}
 		y--
 	}
@@ -132,18 +253,18 @@ func drop_one_rock() bool {
 	return false
 }
 
-func show_stack() {
-	if len(stack.lines) > 10000 {
-		fmt.Printf("Before showing stack, pruning to 10000. Real length was: %d\n", len(stack.lines))
-		stack.offset += len(stack.lines) - 10000
-		stack.lines = stack.lines[len(stack.lines)-10000:]
+func (c *Chamber) show_stack() {
+	if len(c.stack.lines) > 10000 {
+		fmt.Printf("Before showing stack, pruning to 10000. Real length was: %d\n", len(c.stack.lines))
+		c.stack.offset += len(c.stack.lines) - 10000
+		c.stack.lines = c.stack.lines[len(c.stack.lines)-10000:]
 	}
-	for y := len(stack.lines) - 1; y >= 0; y-- {
+	for y := len(c.stack.lines) - 1; y >= 0; y-- {
 		strline := ""
-		var leftmost Line = 1 << (width - 1)
-		for x := 0; x < width; x++ {
+		var leftmost Line = 1 << (c.width - 1)
+		for x := 0; x < c.width; x++ {
 			var char byte
-			if stack.lines[y]&(leftmost>>x) != 0 {
+			if c.stack.lines[y]&(leftmost>>x) != 0 {
 				char = '#'
 			} else {
 				char = '.'
@@ -152,11 +273,11 @@ func show_stack() {
 		}
 		fmt.Printf("|%s|\n", strline)
 	}
-	if stack.offset > 0 {
-		fmt.Printf("(..%d..)\n", stack.offset)
+	if c.stack.offset > 0 {
+		fmt.Printf("(..%d..)\n", c.stack.offset)
 	}
-	fmt.Printf("|%s|\n", strings.Join(make([]string, width+1), "-"))
-	fmt.Printf("Stack size: %d\n", len(stack.lines)+stack.offset)
+	fmt.Printf("|%s|\n", strings.Join(make([]string, c.width+1), "-"))
+	fmt.Printf("Stack size: %d\n", c.height())
 }
 
 const snaplen = 10
@@ -172,50 +293,340 @@ type GameProgress struct {
 
 type MemRepeat map[GamePos]GameProgress
 
-func main() {
-	if len(os.Args) != 2 {
-		panic("Provide input file")
+// columns deeper than this below the top of the stack all look the same in the surface profile
+const max_depth = 255
+
+// how far below the top of the stack the highest rock in each column is. If there is no rock in a column,
+// it is the distance to the bottom of the stack.
+type Profile [max_width]uint8
+
+// only remembered when the first rock is next, a cycle has to start with it anyway
+type SurfacePos struct {
+	streamnr int
+	profile  Profile
+}
+
+type MemSurface map[SurfacePos]GameProgress
+
+// longest cycle looked for. The history of twice that many rocks is kept, and the positions seen in them.
+const default_max_cycle = 1 << 20
+
+// after start rocks, every length rocks add height to the stack
+type Cycle struct {
+	start, length, height int
+	surface               bool // found by the surface profile, instead of after pruning the stack
+}
+
+func (c *Chamber) profile() Profile {
+	var p Profile
+	var found Line
+	depth := 0
+	for y := len(c.stack.lines) - 1; y >= 0 && depth < max_depth && found != c.full_line(); y-- {
+		for top := c.stack.lines[y] &^ found; top != 0; top &= top - 1 {
+			p[bits.TrailingZeros64(uint64(top))] = uint8(depth)
+		}
+		found |= c.stack.lines[y]
+		depth++
 	}
-	starttime := time.Now()
-	bstream, err := os.ReadFile(os.Args[1])
-	if err != nil {
-		panic(err)
-	}
-	stream = strings.TrimRight(string(bstream[:]), "\r\n")
-	setup_field()
-	loadtime := time.Now()
-	fmt.Printf("Loading took: %s\n", loadtime.Sub(starttime))
-	target_rocks := 1_000_000_000_000
-	total_rocks := 0
-	game_repeat := make(MemRepeat)
-	for total_rocks < target_rocks {
-		total_rocks++
-		if drop_one_rock() {
-			// the rock we just dropped pruned the stack.
-			// try to see if we can find a repeat. Only if the stacksize fits in the GamePos struct
-			if len(stack.lines) <= snaplen {
-				this_pos := GamePos{
-					rocknr:   rocknr,
-					streamnr: streamnr,
+	for col := 0; col < c.width; col++ {
+		if found&(1<<col) == 0 {
+			p[col] = uint8(depth)
+		}
+	}
+	return p
+}
+
+// drop rocks until target_rocks have been dropped, and return the height of the stack
+func (c *Chamber) simulate(target_rocks int) int {
+	return c.heights([]int{target_rocks})[0]
+}
+
+// The height of the stack after each of the numbers of rocks in targets, from one simulation.
+// This continues where the last simulation of the chamber stopped. Targets that were already passed must still be
+// in the history.
+func (c *Chamber) heights(targets []int) []int {
+	last_target := 0
+	result := make([]int, len(targets))
+	for i, target := range targets {
+		last_target = max(last_target, target)
+		if target <= c.total_rocks {
+			result[i] = c.height_after(target)
+		}
+	}
+	c.cycle = Cycle{}
+	for c.total_rocks < last_target {
+		c.total_rocks++
+		total_rocks := c.total_rocks
+		pruned := c.drop_one_rock()
+		c.history = append(c.history, c.height())
+		for i, target := range targets {
+			if target == total_rocks {
+				result[i] = c.height()
+			}
+		}
+		if total_rocks%c.max_cycle == 0 {
+			c.forget(total_rocks - c.max_cycle)
+		}
+		if c.autosave != nil {
+			c.autosave.check(c)
+		}
+		// if the rock we just dropped pruned the stack, try to see if we can find a repeat.
+		// Only if the stacksize fits in the GamePos struct
+		if pruned && len(c.stack.lines) <= snaplen {
+			this_pos := GamePos{
+				rocknr:   c.rocknr,
+				streamnr: c.streamnr,
+			}
+			copy(this_pos.stacksnap[:], c.stack.lines)
+			progress, found := c.game_repeat[this_pos]
+			if found && total_rocks-progress.num_rocks <= c.max_cycle {
+				c.cycle = Cycle{start: progress.num_rocks, length: total_rocks - progress.num_rocks, height: c.height() - progress.stack_size}
+			} else {
+				c.game_repeat[this_pos] = GameProgress{
+					num_rocks:  total_rocks,
+					stack_size: c.height(),
 				}
-				copy(this_pos.stacksnap[:], stack.lines)
-				progress, found := game_repeat[this_pos]
-				if found {
-					delta_rocks := total_rocks - progress.num_rocks
-					delta_stack := len(stack.lines) + stack.offset - progress.stack_size
-					repeats := (target_rocks - total_rocks) / delta_rocks
-					total_rocks += repeats * delta_rocks
-					stack.offset += repeats * delta_stack
-				} else {
-					game_repeat[this_pos] = GameProgress{
-						num_rocks:  total_rocks,
-						stack_size: len(stack.lines) + stack.offset,
-					}
+			}
+		} else if c.rocknr == 0 {
+			// Not every stream prunes the stack, then look at the surface of the stack instead.
+			// This does not see everything below the surface, so the cycle is only believed if the heights
+			// during the cycle before it went up the same way.
+			this_pos := SurfacePos{
+				streamnr: c.streamnr,
+				profile:  c.profile(),
+			}
+			progress, found := c.surface_repeat[this_pos]
+			if found && total_rocks-progress.num_rocks <= c.max_cycle && c.repeats_before(progress.num_rocks, total_rocks) {
+				c.cycle = Cycle{start: progress.num_rocks, length: total_rocks - progress.num_rocks, height: c.height() - progress.stack_size, surface: true}
+			} else {
+				c.surface_repeat[this_pos] = GameProgress{
+					num_rocks:  total_rocks,
+					stack_size: c.height(),
 				}
 			}
 		}
+		if c.cycle.length == 0 {
+			continue
+		}
+		// from here on, everything repeats. Every cycle of length rocks adds height to the stack
+		for i, target := range targets {
+			if target > total_rocks {
+				repeats := (target - c.cycle.start) / c.cycle.length
+				result[i] = c.height_after(target-repeats*c.cycle.length) + repeats*c.cycle.height
+			}
+		}
+		return result
+	}
+	return result
+}
+
+func (c *Chamber) height_after(rocks int) int {
+	return c.history[rocks-c.history_start]
+}
+
+// forget the history and the positions before this number of rocks, they are not needed for a cycle anymore.
+// The history is kept for twice the longest cycle, to see if the heights repeat before it.
+func (c *Chamber) forget(rocks int) {
+	for pos, progress := range c.game_repeat {
+		if progress.num_rocks < rocks {
+			delete(c.game_repeat, pos)
+		}
+	}
+	for pos, progress := range c.surface_repeat {
+		if progress.num_rocks < rocks {
+			delete(c.surface_repeat, pos)
+		}
+	}
+	if keep := rocks - c.max_cycle; keep > c.history_start {
+		c.history = append([]int(nil), c.history[keep-c.history_start:]...)
+		c.history_start = keep
+	}
+}
+
+// check if the heights went up the same way in the cycle from start to end, as in the same number of rocks before it
+func (c *Chamber) repeats_before(start int, end int) bool {
+	length := end - start
+	if start-length < c.history_start {
+		return false
+	}
+	for n := start - length; n < start; n++ {
+		if c.height_after(n+length)-c.height_after(n) != c.height_after(end)-c.height_after(start) {
+			return false
+		}
+	}
+	return true
+}
+
+// Solver solves the puzzle of day 17
+type Solver struct {
+	width     int
+	rocksfile string // file with the shapes of the rocks, instead of the ones from the puzzle
+	rocks     []Rock
+	stream    string
+	targets   string // numbers of rocks to show the height of the stack for, separated by commas
+	animate   int    // number of rocks to show falling
+	delay     time.Duration
+	rows      int
+	pngfile   string
+	snapfile  string // file to save snapshots to in part 2
+	every     time.Duration
+	resume    *Snapshot // snapshot to continue part 2 from
+}
+
+func New() *Solver {
+	return &Solver{width: 7, delay: 50 * time.Millisecond, rows: 30, every: time.Minute}
+}
+
+func (s *Solver) Flags(fs *flag.FlagSet) {
+	fs.IntVar(&s.width, "width", s.width, fmt.Sprintf("width of the chamber, at most %d", max_width))
+	fs.StringVar(&s.targets, "heights", s.targets, "also show the height of the stack after these numbers of rocks, separated by commas, in part 2")
+	fs.IntVar(&s.animate, "animate", s.animate, "show this many rocks falling in the terminal, before part 1")
+	fs.DurationVar(&s.delay, "delay", s.delay, "time between two steps of the animation")
+	fs.IntVar(&s.rows, "rows", s.rows, "number of lines of the chamber shown in the animation")
+	fs.StringVar(&s.pngfile, "png", s.pngfile, "write the tower after part 1 as a PNG image to `file`")
+	fs.StringVar(&s.snapfile, "snapshot", s.snapfile, "save the simulation of part 2 to `file` every so often")
+	fs.DurationVar(&s.every, "every", s.every, "time between two snapshots")
+	fs.Func("resume", "continue the simulation of part 2 from a snapshot `file`", func(file string) error {
+		snap, err := read_snapshot(file)
+		s.resume = &snap
+		return err
+	})
+	fs.StringVar(&s.rocksfile, "rocks", s.rocksfile, "`file` with the rocks drawn with # and ., separated by empty lines. It can set the width with a \"width N\" line first")
+}
+
+func (s *Solver) Parse(r io.Reader) error {
+	s.rocks = default_rocks
+	if s.rocksfile != "" {
+		fh, err := os.Open(s.rocksfile)
+		if err != nil {
+			return err
+		}
+		defer fh.Close()
+		var file_width int
+		s.rocks, file_width, err = parse_rocks(fh)
+		if err != nil {
+			if perr, ok := err.(*aoc.ParseError); ok {
+				perr.File = s.rocksfile
+			}
+			return err
+		}
+		if file_width != 0 {
+			s.width = file_width
+		}
+	}
+	if s.width < 1 || s.width > max_width {
+		return fmt.Errorf("width must be 1 to %d, not %d", max_width, s.width)
+	}
+	// rocks appear two units away from the left wall
+	for i, rock := range s.rocks {
+		if rock.x+2 > s.width {
+			return fmt.Errorf("rock %d is %d wide, it does not fit in a chamber %d wide", i+1, rock.x, s.width)
+		}
+	}
+	if s.every < 0 {
+		return fmt.Errorf("cannot save snapshots every %v", s.every)
+	}
+	if s.animate < 0 || s.rows < 1 {
+		return fmt.Errorf("cannot animate %d rocks in %d rows", s.animate, s.rows)
+	}
+	if s.targets != "" {
+		targets, err := parse_targets(s.targets)
+		if err != nil {
+			return fmt.Errorf("invalid -heights %s: %w", s.targets, err)
+		}
+		// the snapshot only has the last part of the history
+		for _, target := range targets {
+			if s.resume != nil && target < s.resume.HistoryStart {
+				return fmt.Errorf("cannot show the height after %d rocks, the snapshot only has the heights from %d rocks on", target, s.resume.HistoryStart)
+			}
+		}
+	}
+	bstream, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	s.stream, err = parse_stream(string(bstream))
+	if err != nil {
+		return err
+	}
+	if s.resume != nil {
+		if err := new_chamber(s.width, s.rocks, s.stream).restore(*s.resume); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Solver) Part1() any {
+	if s.animate > 0 {
+		s.animate_rocks(s.animate)
+	}
+	if s.pngfile != "" {
+		if err := s.write_png(s.pngfile, 2022); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+	return new_chamber(s.width, s.rocks, s.stream).simulate(2022)
+}
+
+// the jets of hot gas, on one line of < and >
+func parse_stream(input string) (string, error) {
+	lines := strings.Split(strings.TrimRight(input, "\r\n"), "\n")
+	stream := strings.TrimSuffix(lines[0], "\r")
+	if stream == "" {
+		return "", &aoc.ParseError{Line: 1, Msg: "no jets"}
+	}
+	if len(lines) > 1 {
+		return "", &aoc.ParseError{Line: 2, Text: lines[1], Msg: "the jets are on one line"}
+	}
+	for i, char := range []byte(stream) {
+		if char != '<' && char != '>' {
+			return "", &aoc.ParseError{Line: 1, Column: i + 1, Text: string(char), Msg: "jets are < or >"}
+		}
+	}
+	return stream, nil
+}
+
+// the numbers of rocks to show the height of the stack for
+func parse_targets(list string) ([]int, error) {
+	var targets []int
+	for _, field := range strings.Split(list, ",") {
+		target, err := strconv.Atoi(strings.ReplaceAll(strings.TrimSpace(field), "_", ""))
+		if err != nil {
+			return nil, err
+		}
+		if target < 0 {
+			return nil, fmt.Errorf("cannot drop %d rocks", target)
+		}
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+func (s *Solver) Part2() any {
+	const target_rocks = 1_000_000_000_000
+	var targets []int
+	if s.targets != "" {
+		targets, _ = parse_targets(s.targets)
+	}
+	chamber := new_chamber(s.width, s.rocks, s.stream)
+	if s.resume != nil {
+		chamber.restore(*s.resume)
+		fmt.Printf("continuing after %d rocks\n", chamber.total_rocks)
+	}
+	if s.snapfile != "" {
+		chamber.autosave = &AutoSave{file: s.snapfile, every: s.every, next: time.Now().Add(s.every)}
+	}
+	heights := chamber.heights(append(targets, target_rocks))
+	if cycle := chamber.cycle; cycle.length > 0 {
+		found_by := "pruning"
+		if cycle.surface {
+			found_by = "surface"
+		}
+		fmt.Printf("cycle of %d rocks adding %d height, starting after %d rocks (found by %s)\n", cycle.length, cycle.height, cycle.start, found_by)
+	}
+	for i, target := range targets {
+		fmt.Printf("height after %d rocks: %d\n", target, heights[i])
 	}
-	part1time := time.Now()
-	show_stack()
-	fmt.Printf("Part 1 took: %s\n", part1time.Sub(starttime))
+	return heights[len(targets)]
 }
@@ -0,0 +1,289 @@
+package day17
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jpcornet/AoC2022/aoc"
+	"github.com/jpcornet/AoC2022/aoc/aoctest"
+)
+
+func Test_rocks(t *testing.T) {
+	tests := []struct {
+		name      string
+		rocksfile string
+		width     int
+		height    int
+		height2   int
+	}{
+		{name: "puzzle rocks", rocksfile: "input/rocks.txt", width: 7, height: 3068, height2: 1514285714288},
+		{name: "wider chamber", rocksfile: "input/rocks.txt", width: 9, height: 2633, height2: 1300000000004},
+		{name: "even wider chamber", width: 12, height: 2440, height2: 1200000000015},
+		{name: "other rocks", rocksfile: "input/wide-rocks.txt", width: 7, height: 3094, height2: 1533333333327},
+	}
+	sample, err := os.ReadFile("input/sample.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	aoctest.Quiet(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New()
+			s.width = tt.width
+			s.rocksfile = tt.rocksfile
+			if err := s.Parse(strings.NewReader(string(sample))); err != nil {
+				t.Fatal(err)
+			}
+			if height := s.Part1(); height != tt.height {
+				t.Errorf("height after 2022 rocks = %v, want %d", height, tt.height)
+			}
+			if height := s.Part2(); height != tt.height2 {
+				t.Errorf("height after 1000000000000 rocks = %v, want %d", height, tt.height2)
+			}
+		})
+	}
+}
+
+// every chamber is separate, so they can run at the same time
+func Test_chamber(t *testing.T) {
+	sample, err := os.ReadFile("input/sample.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		stream string
+		width  int
+		rocks  int
+		height int
+	}{
+		{name: "sample 10 rocks", stream: strings.TrimSpace(string(sample)), width: 7, rocks: 10, height: 17},
+		{name: "sample", stream: strings.TrimSpace(string(sample)), width: 7, rocks: 2022, height: 3068},
+		{name: "short stream", stream: "<<<>>", width: 7, rocks: 2022, height: 3641},
+		{name: "wide chamber", stream: "><", width: 11, rocks: 500, height: 1100},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if height := new_chamber(tt.width, default_rocks, tt.stream).simulate(tt.rocks); height != tt.height {
+				t.Errorf("simulate(%d) = %d, want %d", tt.rocks, height, tt.height)
+			}
+		})
+	}
+}
+
+func Test_heights(t *testing.T) {
+	sample, err := os.ReadFile("input/sample.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream := strings.TrimSpace(string(sample))
+	targets := []int{2022, 10, 1_000_000_000_000, 0, 1_000_000}
+	want := []int{3068, 17, 1514285714288, 0, 1514288}
+	heights := new_chamber(7, default_rocks, stream).heights(targets)
+	for i, target := range targets {
+		if heights[i] != want[i] {
+			t.Errorf("height after %d rocks = %d, want %d", target, heights[i], want[i])
+		}
+	}
+}
+
+// without full rows in the stack, the cycle is found by looking at the surface
+func Test_surface_cycle(t *testing.T) {
+	sample, err := os.ReadFile("input/sample.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := new_chamber(9, default_rocks, strings.TrimSpace(string(sample)))
+	if height := c.simulate(1_000_000_000_000); height != 1300000000004 {
+		t.Errorf("simulate() = %d, want 1300000000004", height)
+	}
+	if !c.cycle.surface || c.cycle.length != 90 || c.cycle.height != 117 {
+		t.Errorf("cycle = %+v, want 90 rocks adding 117, found by the surface", c.cycle)
+	}
+}
+
+// when there is no cycle up to max_cycle rocks, the simulation goes on without remembering more
+func Test_max_cycle(t *testing.T) {
+	sample, err := os.ReadFile("input/sample.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream := strings.TrimSpace(string(sample))
+	targets := []int{2022, 10, 20_000}
+	for _, width := range []int{7, 9} {
+		want := new_chamber(width, default_rocks, stream).heights(targets)
+		// the cycles are 35 and 90 rocks
+		c := new_chamber(width, default_rocks, stream)
+		c.max_cycle = 8
+		heights := c.heights(targets)
+		if c.cycle.length != 0 {
+			t.Errorf("width %d: found a cycle of %d rocks, longer than %d", width, c.cycle.length, c.max_cycle)
+		}
+		for i, target := range targets {
+			if heights[i] != want[i] {
+				t.Errorf("width %d: height after %d rocks = %d, want %d", width, target, heights[i], want[i])
+			}
+		}
+		if len(c.history) > 3*c.max_cycle+1 || len(c.game_repeat) > 2*c.max_cycle || len(c.surface_repeat) > 2*c.max_cycle {
+			t.Errorf("width %d: remembers %d heights, %d and %d positions, want at most %d, %d and %d", width,
+				len(c.history), len(c.game_repeat), len(c.surface_repeat), 3*c.max_cycle+1, 2*c.max_cycle, 2*c.max_cycle)
+		}
+	}
+}
+
+func Test_frame(t *testing.T) {
+	sample, err := os.ReadFile("input/sample.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := new_chamber(7, default_rocks, strings.TrimSpace(string(sample)))
+	c.drop_one_rock()
+	want := "|...@...|\n|..@@@..|\n|...@...|\n|.......|\n|.......|\n|.......|\n|..####.|\n+-------+\n"
+	if frame := c.frame(default_rocks[1], 2, 4, 10); frame != want {
+		t.Errorf("frame() =\n%s\nwant\n%s", frame, want)
+	}
+	if frame := c.frame(default_rocks[1], 2, 4, 3); frame != "|...@...|\n|..@@@..|\n|...@...|\n(..4..)\n" {
+		t.Errorf("frame() with 3 rows =\n%s", frame)
+	}
+	var buf bytes.Buffer
+	c.animation = &Animation{w: &buf, rows: 10}
+	c.drop_one_rock()
+	// the rock appears, then it is pushed by 4 jets and falls 3 times before it lands
+	if frames := strings.Count(buf.String(), "\033[2J"); frames != 4 {
+		t.Errorf("animation has %d frames, want 4", frames)
+	}
+	// the second rock of the list is falling, pushed by the jets after the 4 used by the first rock
+	if !strings.Contains(buf.String(), "rock 1, next jet 4\n") || !strings.Contains(buf.String(), "rock 1, next jet 7\n") {
+		t.Errorf("animation does not show the falling rock and the next jet:\n%s", buf.String())
+	}
+}
+
+func Test_tower_image(t *testing.T) {
+	sample, err := os.ReadFile("input/sample.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := new_chamber(7, default_rocks, strings.TrimSpace(string(sample)))
+	c.tower = new_tower(7)
+	for i := 0; i < 10; i++ {
+		c.drop_one_rock()
+	}
+	img := c.tower.image(Cycle{})
+	if size := img.Bounds().Size(); size.X != 11*cell_size || size.Y != 18*cell_size {
+		t.Errorf("image is %dx%d, want %dx%d", size.X, size.Y, 11*cell_size, 18*cell_size)
+	}
+	// the first rock is the horizontal bar, from the third column, just above the floor
+	if c := img.RGBAAt(4*cell_size, 16*cell_size); c != rock_colors[0] {
+		t.Errorf("first rock has color %v, want %v", c, rock_colors[0])
+	}
+	if c := img.RGBAAt(3*cell_size, 16*cell_size); c != air_color {
+		t.Errorf("left of the first rock has color %v, want %v", c, air_color)
+	}
+}
+
+func Test_snapshot(t *testing.T) {
+	sample, err := os.ReadFile("input/sample.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream := strings.TrimSpace(string(sample))
+	// stop before the cycle is found
+	c := new_chamber(7, default_rocks, stream)
+	c.simulate(40)
+	file := filepath.Join(t.TempDir(), "snapshot")
+	if err := c.snapshot().write(file); err != nil {
+		t.Fatal(err)
+	}
+	snap, err := read_snapshot(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c = new_chamber(7, default_rocks, stream)
+	if err := c.restore(snap); err != nil {
+		t.Fatal(err)
+	}
+	if heights := c.heights([]int{10, 1_000_000_000_000}); heights[0] != 17 || heights[1] != 1514285714288 {
+		t.Errorf("heights() after restore = %v, want [17 1514285714288]", heights)
+	}
+	if err := new_chamber(9, default_rocks, stream).restore(snap); err == nil {
+		t.Errorf("restore() in a wider chamber did not fail")
+	}
+	// a snapshot of a long simulation only has the last part of it
+	c = new_chamber(9, default_rocks, stream)
+	c.max_cycle = 8
+	c.simulate(5000)
+	snap = c.snapshot()
+	if len(snap.History) > 3*c.max_cycle+1 || len(snap.Repeats)+len(snap.Surfaces) > 2*c.max_cycle {
+		t.Errorf("snapshot has %d heights and %d positions, want at most %d and %d", len(snap.History), len(snap.Repeats)+len(snap.Surfaces), 3*c.max_cycle+1, 2*c.max_cycle)
+	}
+	c = new_chamber(9, default_rocks, stream)
+	if err := c.restore(snap); err != nil {
+		t.Fatal(err)
+	}
+	if height := c.simulate(20_000); height != 26004 {
+		t.Errorf("simulate() after restore = %d, want 26004", height)
+	}
+	s := New()
+	s.width, s.targets, s.resume = 9, "10", &snap
+	if err := s.Parse(strings.NewReader(stream)); err == nil {
+		t.Errorf("Parse() with a height from before the snapshot did not fail")
+	}
+}
+
+func Test_parse_rocks(t *testing.T) {
+	rocks, w, err := parse_rocks(strings.NewReader("width 9\n\n.#.\n###\n.#.\n\n#\n#\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w != 9 || len(rocks) != 2 {
+		t.Fatalf("parse_rocks() = %d rocks, width %d, want 2 rocks, width 9", len(rocks), w)
+	}
+	if rocks[0].x != 3 || rocks[0].y != 3 || rocks[0].lines[0] != 0x2 || rocks[0].lines[1] != 0x7 {
+		t.Errorf("parse_rocks() first rock = %+v", rocks[0])
+	}
+	for _, input := range []string{"", "#\n\n#x\n", "...\n", "#\nwidth 9\n", "width 65\n#\n"} {
+		if _, _, err := parse_rocks(strings.NewReader(input)); err == nil {
+			t.Errorf("parse_rocks(%q) gives no error", input)
+		}
+	}
+}
+
+func Test_parse_stream(t *testing.T) {
+	if stream, err := parse_stream("<<>\r\n"); err != nil || stream != "<<>" {
+		t.Errorf("parse_stream() = %q, %v, want \"<<>\"", stream, err)
+	}
+	tests := []struct {
+		name         string
+		input        string
+		line, column int
+		text         string
+	}{
+		{name: "empty", input: "", line: 1},
+		{name: "empty line", input: "\n", line: 1},
+		{name: "other char", input: "<<>x>\n", line: 1, column: 4, text: "x"},
+		{name: "space", input: "<< >\n", line: 1, column: 3, text: " "},
+		{name: "two lines", input: "<<>\n><\n", line: 2, text: "><"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parse_stream(tt.input)
+			var perr *aoc.ParseError
+			if !errors.As(err, &perr) {
+				t.Fatalf("parse_stream() error = %v, want a ParseError", err)
+			}
+			if perr.Line != tt.line || perr.Column != tt.column || perr.Text != tt.text {
+				t.Errorf("parse_stream() error at %d:%d [%s], want %d:%d [%s]", perr.Line, perr.Column, perr.Text, tt.line, tt.column, tt.text)
+			}
+		})
+	}
+}
+
+func BenchmarkSolver(b *testing.B) {
+	aoctest.Benchmark(b, func() aoc.Solver { return New() }, aoctest.BenchInput("input"))
+}
@@ -1,195 +1,126 @@
-package main
+package day18
 
 import (
+	"bufio"
+	"flag"
 	"fmt"
-	"math"
+	"io"
 	"os"
 	"strconv"
 	"strings"
-	"time"
+
+	"github.com/jpcornet/AoC2022/aoc"
+	"github.com/jpcornet/AoC2022/voxel"
 )
 
-type Cube [3]int
+type Cube = voxel.Cube
 
-func parse_input(filename string) []Cube {
-	inputstr, err := os.ReadFile(filename)
-	if err != nil {
-		panic(err)
-	}
-	inputlines := strings.Split(string(inputstr[:]), "\n")
-	if inputlines[len(inputlines)-1] == "" {
-		inputlines = inputlines[:len(inputlines)-1]
-	}
-	result := make([]Cube, 0, len(inputlines))
-	for _, l := range inputlines {
+func parse_input(r io.Reader) ([]Cube, error) {
+	scanner := bufio.NewScanner(r)
+	result := make([]Cube, 0, 1000)
+	for lnr := 0; scanner.Scan(); lnr++ {
+		l := scanner.Text()
 		xyzstr := strings.Split(l, ",")
 		if len(xyzstr) != 3 {
-			panic(fmt.Sprintf("invalid input line [%s] converted to: %v\n", l, xyzstr))
+			return nil, &aoc.ParseError{Line: lnr + 1, Text: l, Msg: fmt.Sprintf("expected 3 coords, got %d", len(xyzstr))}
 		}
 		var cube Cube
+		col := 0
 		for i, coord := range xyzstr {
 			var err error
 			cube[i], err = strconv.Atoi(coord)
 			if err != nil {
-				panic(err)
+				return nil, &aoc.ParseError{Line: lnr + 1, Column: col + 1, Text: coord, Msg: "invalid coord", Err: err}
 			}
+			col += len(coord) + 1
 		}
 		result = append(result, cube)
 	}
-	return result
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
 }
 
-var directions = []Cube{
-	{1, 0, 0},
-	{-1, 0, 0},
-	{0, 1, 0},
-	{0, -1, 0},
-	{0, 0, 1},
-	{0, 0, -1},
+// write every face of the boulder that is not covered by another cube as a mesh, in OBJ or STL format
+func write_mesh(file string, boulder []Cube, stl bool) error {
+	mesh := voxel.NewMesh(voxel.NewSet(boulder).Faces())
+	fh, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if stl {
+		err = mesh.WriteSTL(fh, "boulder")
+	} else {
+		err = mesh.WriteOBJ(fh)
+	}
+	if err != nil {
+		fh.Close()
+		return err
+	}
+	return fh.Close()
 }
 
-func calc_surface(boulder []Cube) int {
-	cubes := make(map[Cube]bool)
-	for _, c := range boulder {
-		cubes[c] = true
-	}
+// Solver solves the puzzle of day 18
+type Solver struct {
+	boulder []Cube
+	grid    *voxel.Grid
+	objfile string
+	stlfile string
+	pockets bool // show the pockets of air inside the boulder
+	rules   voxel.Rules
+}
+
+func New() *Solver {
+	return &Solver{rules: voxel.Rules{Air: 6, Solid: 26}}
+}
+
+func (s *Solver) Flags(fs *flag.FlagSet) {
+	fs.StringVar(&s.objfile, "obj", s.objfile, "write the surface of part 1 as a Wavefront OBJ mesh to `file`")
+	fs.StringVar(&s.stlfile, "stl", s.stlfile, "write the surface of part 1 as an STL mesh to `file`")
+	fs.IntVar(&s.rules.Air, "air", s.rules.Air, "steam moves to the 6 cubes around it that share a face, 18 that share an edge, or 26 that share a corner")
+	fs.IntVar(&s.rules.Solid, "solid", s.rules.Solid, "cubes are connected if they share a face (6), an edge (18) or a corner (26), steam cannot squeeze between them")
+	fs.BoolVar(&s.pockets, "pockets", s.pockets, "show every pocket of air inside the boulder in part 2")
+}
+
+// show the pockets of air, biggest first
+func show_pockets(pockets []voxel.AirPocket) {
 	surface := 0
-	for _, c := range boulder {
-		for _, dir := range directions {
-			testc := Cube{c[0] + dir[0], c[1] + dir[1], c[2] + dir[2]}
-			_, ok := cubes[testc]
-			if !ok {
-				// count as a surface if there is no cube in this direction
-				surface++
-			}
-		}
+	for i, p := range pockets {
+		fmt.Printf("pocket %d: volume %d, from %d,%d,%d to %d,%d,%d, surface %d\n", i+1, p.Volume,
+			p.Min[0], p.Min[1], p.Min[2], p.Max[0], p.Max[1], p.Max[2], p.Surface)
+		surface += p.Surface
 	}
-	return surface
+	fmt.Printf("%d pockets, surface %d\n", len(pockets), surface)
 }
 
-func check_interior(pos Cube, boulder map[Cube]bool, exterior map[Cube]bool) (bool, map[Cube]bool) {
-	// keep a map of everywhere we've been
-	seen := make(map[Cube]bool)
-	// keep walkers
-	walkers := make([]Cube, 0, 10)
-	walkers = append(walkers, pos)
-	seen[pos] = true
-	for len(walkers) > 0 {
-		w := walkers[0]
-		walkers = walkers[1:]
-		for _, dir := range directions {
-			nextw := Cube{w[0] + dir[0], w[1] + dir[1], w[2] + dir[2]}
-			_, in_boulder := boulder[nextw]
-			if in_boulder {
-				// we walked into the boulder
-				continue
-			}
-			_, beenthere := seen[nextw]
-			if beenthere {
-				// we've already been here
-				continue
-			}
-			_, in_ext := exterior[nextw]
-			if in_ext {
-				// it's connected to the exterior!
-				return false, seen
-			}
-			seen[nextw] = true
-			walkers = append(walkers, nextw)
-		}
+func (s *Solver) Parse(r io.Reader) (err error) {
+	s.boulder, err = parse_input(r)
+	if err != nil {
+		return err
 	}
-	return true, seen
+	s.grid = voxel.NewGrid(s.boulder)
+	return s.grid.SetRules(s.rules)
 }
 
-func add_interior_bubbles(boulder *[]Cube) {
-	// again, need a map of cubes present
-	cubes := make(map[Cube]bool)
-	// also collect x, y, z ranges
-	min := [3]int{math.MaxInt, math.MaxInt, math.MaxInt}
-	max := [3]int{math.MinInt, math.MinInt, math.MinInt}
-	for _, c := range *boulder {
-		cubes[c] = true
-		for i, coord := range c {
-			if coord < min[i] {
-				min[i] = coord
-			}
-			if coord > max[i] {
-				max[i] = coord
-			}
-		}
-	}
-	// keep a map of all cubes that are connected to the exterior
-	exterior := make(map[Cube]bool)
-	// first, mark everything around the boulder as exterior
-	// Top and bottom
-	for x := min[0] - 1; x <= max[0]+1; x++ {
-		for y := min[1] - 1; y <= max[1]+1; y++ {
-			exterior[Cube{x, y, min[2] - 1}] = true
-			exterior[Cube{x, y, max[2] + 1}] = true
-		}
-	}
-	// front and back
-	for x := min[0] - 1; x <= max[0]+1; x++ {
-		for z := min[2] - 1; z <= max[2]+1; z++ {
-			exterior[Cube{x, min[1] - 1, z}] = true
-			exterior[Cube{x, max[1] + 1, z}] = true
-		}
-	}
-	// left and right
-	for y := min[1] - 1; y <= max[1]+1; y++ {
-		for z := min[2] - 1; z <= max[2]+1; z++ {
-			exterior[Cube{min[0] - 1, y, z}] = true
-			exterior[Cube{max[0] + 1, y, z}] = true
+func (s *Solver) Part1() any {
+	if s.objfile != "" {
+		if err := write_mesh(s.objfile, s.boulder, false); err != nil {
+			fmt.Fprintln(os.Stderr, err)
 		}
 	}
-	// now walk every possible cube within that area
-	for x := min[0]; x <= max[0]; x++ {
-		for y := min[1]; y <= max[1]; y++ {
-			for z := min[2]; z <= max[2]; z++ {
-				this_c := Cube{x, y, z}
-				_, isboulder := cubes[this_c]
-				if isboulder {
-					// part of the boulder, just continue
-					continue
-				}
-				_, isext := exterior[this_c]
-				if isext {
-					// part of the exterior
-					continue
-				}
-				is_int, seen := check_interior(this_c, cubes, exterior)
-				if is_int {
-					// if it is interior, add everything to the boulder itself
-					for c := range seen {
-						*boulder = append(*boulder, c)
-						cubes[c] = true
-					}
-				} else {
-					// if it isn't interior, it's exterior
-					for c := range seen {
-						exterior[c] = true
-					}
-				}
-			}
+	if s.stlfile != "" {
+		if err := write_mesh(s.stlfile, s.boulder, true); err != nil {
+			fmt.Fprintln(os.Stderr, err)
 		}
 	}
+	return s.grid.Surface(s.boulder, false)
 }
 
-func main() {
-	if len(os.Args) != 2 {
-		panic("Provide input file")
+func (s *Solver) Part2() any {
+	if s.pockets {
+		show_pockets(s.grid.Pockets(s.boulder))
 	}
-	starttime := time.Now()
-	boulder := parse_input(os.Args[1])
-	parsetime := time.Now()
-	surface := calc_surface(boulder)
-	calctime := time.Now()
-	add_interior_bubbles(&boulder)
-	surface2 := calc_surface(boulder)
-	calctime2 := time.Now()
-	fmt.Printf("Surface part1=%d\n", surface)
-	fmt.Printf("Surface part2=%d\n", surface2)
-	fmt.Printf("Parse took: %s\n", parsetime.Sub(starttime))
-	fmt.Printf("Surface calc took: %s\n", calctime.Sub(parsetime))
-	fmt.Printf("part 2 took: %s\n", calctime2.Sub(calctime))
+	s.grid.FillOutside()
+	return s.grid.Surface(s.boulder, true)
 }
@@ -0,0 +1,40 @@
+package day18
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jpcornet/AoC2022/aoc"
+	"github.com/jpcornet/AoC2022/aoc/aoctest"
+)
+
+func Test_parse_input_errors(t *testing.T) {
+	tests := []struct {
+		name         string
+		input        string
+		line, column int
+		text         string
+	}{
+		{name: "two coords", input: "1,2,3\n1,2\n", line: 2, text: "1,2"},
+		{name: "four coords", input: "1,2,3,4\n", line: 1, text: "1,2,3,4"},
+		{name: "invalid coord", input: "1,2,3\n4,x5,6\n", line: 2, column: 3, text: "x5"},
+		{name: "empty line", input: "1,2,3\n\n", line: 2, text: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parse_input(strings.NewReader(tt.input))
+			var perr *aoc.ParseError
+			if !errors.As(err, &perr) {
+				t.Fatalf("parse_input() error = %v, want a ParseError", err)
+			}
+			if perr.Line != tt.line || perr.Column != tt.column || perr.Text != tt.text {
+				t.Errorf("parse_input() error at %d:%d [%s], want %d:%d [%s]", perr.Line, perr.Column, perr.Text, tt.line, tt.column, tt.text)
+			}
+		})
+	}
+}
+
+func BenchmarkSolver(b *testing.B) {
+	aoctest.Benchmark(b, func() aoc.Solver { return New() }, aoctest.BenchInput("input"))
+}
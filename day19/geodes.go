@@ -1,12 +1,14 @@
-package main
+package day19
 
 import (
+	"bytes"
 	"fmt"
-	"os"
+	"io"
 	"regexp"
 	"sort"
 	"strconv"
-	"time"
+
+	"github.com/jpcornet/AoC2022/aoc"
 )
 
 type Blueprint struct {
@@ -32,10 +34,10 @@ type State struct {
 	geode_robot int
 }
 
-func parse_input(filename string) []Blueprint {
-	inbuf, err := os.ReadFile(filename)
+func parse_input(r io.Reader) ([]Blueprint, error) {
+	inbuf, err := io.ReadAll(r)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 	bp_re := regexp.MustCompile(`^Blueprint (\d+):\s+` +
 		`Each ore robot costs (\d+) ore.\s+` +
@@ -43,10 +45,17 @@ func parse_input(filename string) []Blueprint {
 		`Each obsidian robot costs (\d+) ore and (\d+) clay.\s+` +
 		`Each geode robot costs (\d+) ore and (\d+) obsidian.\s+`)
 	var results []Blueprint
+	// keep the whole input around to be able to report where an error is
+	input := inbuf
 	for len(inbuf) > 0 {
 		match := bp_re.FindSubmatchIndex(inbuf)
 		if match == nil {
-			panic(fmt.Sprintf("Cannot parse input at: [%s...]\n", string(inbuf[:40])))
+			// blueprints span multiple lines, determine line and column of where we are
+			offset := len(input) - len(inbuf)
+			line := bytes.Count(input[:offset], []byte("\n")) + 1
+			column := offset - bytes.LastIndexByte(input[:offset], '\n')
+			text, _, _ := bytes.Cut(inbuf, []byte("\n"))
+			return nil, &aoc.ParseError{Line: line, Column: column, Text: string(text), Msg: "not a valid blueprint"}
 		}
 		if match[0] != 0 {
 			panic(fmt.Sprintf("Logic error, expected match start at 0, not at %d\n", match[0]))
@@ -64,7 +73,7 @@ func parse_input(filename string) []Blueprint {
 		inbuf = inbuf[match[1]:]
 		results = append(results, bp)
 	}
-	return results
+	return results, nil
 }
 
 // Path is a collection of states. Current state is the final one.
@@ -287,40 +296,44 @@ func get_max_geodes(bp Blueprint, state State) Path {
 	return solutions[0]
 }
 
-func main() {
-	if len(os.Args) != 2 {
-		panic("Provide input file")
-	}
-	starttime := time.Now()
-	blueprints := parse_input(os.Args[1])
-	parsetime := time.Now()
+// Solver solves the puzzle of day 19
+type Solver struct {
+	blueprints []Blueprint
+}
+
+func New() *Solver {
+	return &Solver{}
+}
+
+func (s *Solver) Parse(r io.Reader) (err error) {
+	s.blueprints, err = parse_input(r)
+	return err
+}
+
+func (s *Solver) Part1() any {
 	total_quality := 0
-	for _, bp := range blueprints {
+	for _, bp := range s.blueprints {
 		var state State
 		state.ore_robot = 1
 		state.timeleft = 24
-		// experimentally, keeping a top 14 is enough, so max 150 solutions is more than enough
 		result := get_max_geodes(bp, state)
 		final := result.states[len(result.states)-1]
 		fmt.Printf("Blueprint #%d produces max %d geodes, with: %v\n", bp.nr, final.geode, result)
 		total_quality += bp.nr * final.geode
 	}
-	part1time := time.Now()
-	fmt.Printf("Total quality: %d\n", total_quality)
-	fmt.Printf("Parse took: %s\n", parsetime.Sub(starttime))
-	fmt.Printf("part 1 took: %s\n", part1time.Sub(parsetime))
+	return total_quality
+}
+
+func (s *Solver) Part2() any {
 	multiple := 1
-	for _, bp := range blueprints[:intmin(3, len(blueprints))] {
+	for _, bp := range s.blueprints[:intmin(3, len(s.blueprints))] {
 		var state State
 		state.ore_robot = 1
 		state.timeleft = 32
-		// experimentally, keeping a top 93 is enough, so 1000 should definately do it
 		result := get_max_geodes(bp, state)
 		final := result.states[len(result.states)-1]
 		fmt.Printf("Blueprint #%d produces max %d geodes, with: %v\n", bp.nr, final.geode, result)
 		multiple *= final.geode
 	}
-	part2time := time.Now()
-	fmt.Printf("Multiplied number of geodes: %d\n", multiple)
-	fmt.Printf("part 2 took: %s\n", part2time.Sub(part1time))
+	return multiple
 }
@@ -0,0 +1,39 @@
+package day19
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jpcornet/AoC2022/aoc"
+	"github.com/jpcornet/AoC2022/aoc/aoctest"
+)
+
+func Test_parse_input_errors(t *testing.T) {
+	tests := []struct {
+		name         string
+		input        string
+		line, column int
+		text         string
+	}{
+		{name: "invalid cost", input: "Blueprint 1: Each ore robot costs 4 ore. Each clay robot costs 2 ore. Each obsidian robot costs 3 ore and 14 clay. Each geode robot costs 2 ore and 7 obsidian.\n" + "Blueprint 2: Each ore robot costs x ore.\n", line: 2, column: 1, text: "Blueprint 2: Each ore robot costs x ore."},
+		{name: "indented", input: "Blueprint 1: Each ore robot costs 4 ore. Each clay robot costs 2 ore. Each obsidian robot costs 3 ore and 14 clay. Each geode robot costs 2 ore and 7 obsidian.\n" + "  Blueprint 2:\n", line: 2, column: 3, text: "Blueprint 2:"},
+		{name: "missing geode robot", input: "Blueprint 1:\n Each ore robot costs 4 ore.\n Each clay robot costs 2 ore.\n", line: 1, column: 1, text: "Blueprint 1:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parse_input(strings.NewReader(tt.input))
+			var perr *aoc.ParseError
+			if !errors.As(err, &perr) {
+				t.Fatalf("parse_input() error = %v, want a ParseError", err)
+			}
+			if perr.Line != tt.line || perr.Column != tt.column || perr.Text != tt.text {
+				t.Errorf("parse_input() error at %d:%d [%s], want %d:%d [%s]", perr.Line, perr.Column, perr.Text, tt.line, tt.column, tt.text)
+			}
+		})
+	}
+}
+
+func BenchmarkSolver(b *testing.B) {
+	aoctest.Benchmark(b, func() aoc.Solver { return New() }, aoctest.BenchInput("input"))
+}
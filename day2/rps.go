@@ -1,11 +1,11 @@
-package main
+package day2
 
 import (
-	"fmt"
-	"log"
-	"os"
+	"bufio"
+	"io"
 	"strings"
-	"time"
+
+	"github.com/jpcornet/AoC2022/aoc"
 )
 
 type Hand int
@@ -41,27 +41,37 @@ var lookuphand = map[byte]Hand{
 	'Z': Scissors,
 }
 
-func part1(inputlines []string) {
-	starttime := time.Now()
-	totalscore := 0
-	for idx, line := range inputlines {
+// every line is their hand, a space, and our hand or how the round should end
+func parse_input(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	var inputlines []string
+	for lnr := 0; scanner.Scan(); lnr++ {
+		line := scanner.Text()
 		if len(line) != 3 {
-			if idx != len(inputlines)-1 {
-				log.Fatal(fmt.Sprintf("Invalid line [%s] at line %d", line, idx))
-			}
-			continue
+			return nil, &aoc.ParseError{Line: lnr + 1, Text: line, Msg: "expected 3 characters"}
 		}
 		if line[1] != ' ' {
-			log.Fatal(fmt.Sprintf("No space separator in [%s] at line %d", line, idx))
+			return nil, &aoc.ParseError{Line: lnr + 1, Column: 2, Text: line[1:2], Msg: "no space separator"}
 		}
-		theirhand, ok := lookuphand[line[0]]
-		if !ok {
-			log.Fatal(fmt.Sprintf("Invalid item %c at line %d", line[0], idx))
+		if !strings.Contains("ABC", line[0:1]) {
+			return nil, &aoc.ParseError{Line: lnr + 1, Column: 1, Text: line[0:1], Msg: "invalid item, expected A, B or C"}
 		}
-		ourhand, ok := lookuphand[line[2]]
-		if !ok {
-			log.Fatal(fmt.Sprintf("Invalid item %c at line %d", line[2], idx))
+		if !strings.Contains("XYZ", line[2:3]) {
+			return nil, &aoc.ParseError{Line: lnr + 1, Column: 3, Text: line[2:3], Msg: "invalid item, expected X, Y or Z"}
 		}
+		inputlines = append(inputlines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return inputlines, nil
+}
+
+func part1(inputlines []string) int {
+	totalscore := 0
+	for _, line := range inputlines {
+		theirhand := lookuphand[line[0]]
+		ourhand := lookuphand[line[2]]
 		score := ourhand.Score()
 		if theirhand == ourhand {
 			score += 3
@@ -71,22 +81,13 @@ func part1(inputlines []string) {
 		//fmt.Printf("Line [%s] they have %#v we have %#v scores %d\n", line, theirhand, ourhand, score)
 		totalscore += score
 	}
-	elapsed := time.Now().Sub(starttime)
-	fmt.Printf("part 1 Total score: %d\n", totalscore)
-	fmt.Printf("Elapsed: %s\n", elapsed)
+	return totalscore
 }
 
-func part2(inputlines []string) {
-	starttime := time.Now()
+func part2(inputlines []string) int {
 	totalscore := 0
-	for idx, line := range inputlines {
-		if len(line) != 3 {
-			continue
-		}
-		theirhand, ok := lookuphand[line[0]]
-		if !ok {
-			log.Fatal(fmt.Sprintf("Invalid item %c at line %d", line[0], idx))
-		}
+	for _, line := range inputlines {
+		theirhand := lookuphand[line[0]]
 		var ourhand Hand
 		switch line[2] {
 		case 'X':
@@ -100,22 +101,28 @@ func part2(inputlines []string) {
 		}
 		totalscore += ourhand.Score()
 	}
-	elapsed := time.Now().Sub(starttime)
-	fmt.Printf("part 2 Total score: %d\n", totalscore)
-	fmt.Printf("Elapsed: %s\n", elapsed)
+	return totalscore
 }
 
-func main() {
-	if len(os.Args) <= 1 {
-		log.Fatal("Provide input file")
-	}
-	// read input file
-	inputFile := os.Args[1]
-	inputstr, err := os.ReadFile(inputFile)
-	if err != nil {
-		log.Fatal(err)
-	}
-	inputlines := strings.Split(string(inputstr[:]), "\n")
-	part1(inputlines)
-	part2(inputlines)
+// Solver solves the puzzle of day 2
+type Solver struct {
+	inputlines []string
+}
+
+func New() *Solver {
+	return &Solver{}
+}
+
+func (s *Solver) Parse(r io.Reader) error {
+	var err error
+	s.inputlines, err = parse_input(r)
+	return err
+}
+
+func (s *Solver) Part1() any {
+	return part1(s.inputlines)
+}
+
+func (s *Solver) Part2() any {
+	return part2(s.inputlines)
 }
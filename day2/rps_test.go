@@ -0,0 +1,41 @@
+package day2
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jpcornet/AoC2022/aoc"
+	"github.com/jpcornet/AoC2022/aoc/aoctest"
+)
+
+func Test_parse_input_errors(t *testing.T) {
+	tests := []struct {
+		name         string
+		input        string
+		line, column int
+		text         string
+	}{
+		{name: "short line", input: "A Y\nB\n", line: 2, text: "B"},
+		{name: "empty line", input: "A Y\n\nC Z\n", line: 2, text: ""},
+		{name: "no space", input: "A Y\nB-X\n", line: 2, column: 2, text: "-"},
+		{name: "their hand", input: "X Y\n", line: 1, column: 1, text: "X"},
+		{name: "our hand", input: "A Y\nC C\n", line: 2, column: 3, text: "C"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parse_input(strings.NewReader(tt.input))
+			var perr *aoc.ParseError
+			if !errors.As(err, &perr) {
+				t.Fatalf("parse_input() error = %v, want a ParseError", err)
+			}
+			if perr.Line != tt.line || perr.Column != tt.column || perr.Text != tt.text {
+				t.Errorf("parse_input() error at %d:%d [%s], want %d:%d [%s]", perr.Line, perr.Column, perr.Text, tt.line, tt.column, tt.text)
+			}
+		})
+	}
+}
+
+func BenchmarkSolver(b *testing.B) {
+	aoctest.Benchmark(b, func() aoc.Solver { return New() }, aoctest.BenchInput("input"))
+}
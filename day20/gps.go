@@ -1,12 +1,13 @@
-package main
+package day20
 
 import (
+	"bufio"
 	"fmt"
-	"os"
+	"io"
 	"sort"
 	"strconv"
-	"strings"
-	"time"
+
+	"github.com/jpcornet/AoC2022/aoc"
 )
 
 type NumEntry struct {
@@ -27,27 +28,39 @@ const spacing = 1 << 32
 
 const minspacing = 1 << 8
 
-func parse_input(filename string) NumList {
-	inbuf, err := os.ReadFile(filename)
-	if err != nil {
-		panic(err)
-	}
+func parse_input(r io.Reader) (NumList, error) {
+	scanner := bufio.NewScanner(r)
 	entries := make([]NumEntry, 0)
 	positions := make([]int, 0)
 	pos := spacing / 2
-	for _, line := range strings.Split(string(inbuf[:]), "\n") {
+	// the grove coordinates are counted from the only 0
+	zeros := 0
+	for lnr := 0; scanner.Scan(); lnr++ {
+		line := scanner.Text()
 		if len(line) == 0 {
 			continue
 		}
 		if val, err := strconv.Atoi(line); err == nil {
+			if val == 0 {
+				zeros++
+				if zeros > 1 {
+					return NumList{}, &aoc.ParseError{Line: lnr + 1, Column: 1, Text: line, Msg: "more than one 0"}
+				}
+			}
 			entries = append(entries, NumEntry{val: val, pos: pos})
 			positions = append(positions, pos)
 			pos += spacing
 		} else {
-			panic(fmt.Sprintf("Cannot convert `%s' to numeric: %s", line, err))
+			return NumList{}, &aoc.ParseError{Line: lnr + 1, Column: 1, Text: line, Msg: "not a number", Err: err}
 		}
 	}
-	return NumList{entries: entries, positions: positions}
+	if err := scanner.Err(); err != nil {
+		return NumList{}, err
+	}
+	if zeros == 0 {
+		return NumList{}, &aoc.ParseError{Line: 1, Msg: "no 0 in the list"}
+	}
+	return NumList{entries: entries, positions: positions}, nil
 }
 
 func (nl NumList) Len() int { return len(nl.entries) }
@@ -197,24 +210,41 @@ func (nl *NumList) Decrypt(key int) {
 	}
 }
 
-func main() {
-	if len(os.Args) != 2 {
-		panic("Provide input file")
+// Solver solves the puzzle of day 20
+type Solver struct {
+	numlist NumList
+}
+
+func New() *Solver {
+	return &Solver{}
+}
+
+func (s *Solver) Parse(r io.Reader) (err error) {
+	s.numlist, err = parse_input(r)
+	return err
+}
+
+// sum the numbers at 1000, 2000 and 3000 after the 0
+func (nl NumList) GroveCoordinates() int {
+	sum := 0
+	for _, offset := range []int{1000, 2000, 3000} {
+		num := nl.Offset0(offset)
+		fmt.Printf("Number at offset %d is %d\n", offset, num)
+		sum += num
 	}
-	starttime := time.Now()
-	numlist := parse_input(os.Args[1])
-	parsetime := time.Now()
+	return sum
+}
+
+func (s *Solver) Part1() any {
+	numlist := &s.numlist
 	for i := 0; i < numlist.Len(); i++ {
 		numlist.Move(i)
 	}
-	part1 := 0
-	for _, offset := range []int{1000, 2000, 3000} {
-		num := numlist.Offset0(offset)
-		fmt.Printf("Number at offset %d is %d\n", offset, num)
-		part1 += num
-	}
-	part1time := time.Now()
-	fmt.Println("part1 sum: ", part1)
+	return numlist.GroveCoordinates()
+}
+
+func (s *Solver) Part2() any {
+	numlist := &s.numlist
 	numlist.Reset()
 	numlist.Decrypt(811589153)
 	for round := 1; round <= 10; round++ {
@@ -225,15 +255,5 @@ func main() {
 		numlist.Rebalance()
 		//fmt.Printf("After round %d, list = %s\n", round, numlist.Str())
 	}
-	part2 := 0
-	for _, offset := range []int{1000, 2000, 3000} {
-		num := numlist.Offset0(offset)
-		fmt.Printf("Number at offset %d is %d\n", offset, num)
-		part2 += num
-	}
-	part2time := time.Now()
-	fmt.Printf("part 2 sum: %d\n", part2)
-	fmt.Printf("Parse took: %s\n", parsetime.Sub(starttime))
-	fmt.Printf("Part 1 took: %s\n", part1time.Sub(parsetime))
-	fmt.Printf("Part 2 took: %s\n", part2time.Sub(part1time))
+	return numlist.GroveCoordinates()
 }
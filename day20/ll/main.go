@@ -1,55 +1,49 @@
-package main
+package ll
 // copied from https://github.com/alexchao26/advent-of-code-go
 // and adapted to be used stand-alone, without embed, and provide timing info
 
 import (
-	"flag"
+	"errors"
 	"fmt"
-	"os"
+	"io"
 	"strconv"
 	"strings"
-	"time"
 )
 
-var input string
-
-func readfile(filename string) {
-	inbuf, err := os.ReadFile(filename)
+func readinput(r io.Reader) (string, error) {
+	inbuf, err := io.ReadAll(r)
 	if err != nil {
-		panic(err)
+		return "", err
 	}
-	input = strings.TrimRight(string(inbuf[:]), "\n")
+	input := strings.TrimRight(string(inbuf[:]), "\n")
 	if len(input) == 0 {
-		panic("empty input.txt file")
+		return "", errors.New("empty input.txt file")
 	}
+	return input, nil
 }
 
 const part2DecryptionKey = 811589153
 
-func main() {
-	var part int
-	var filename string
-	flag.IntVar(&part, "part", 1, "part 1 or 2")
-	flag.StringVar(&filename, "in", "", "input file")
-	flag.Parse()
-	if filename != "" {
-		readfile(filename)
-	} else {
-		panic("Specify input file with --in=filename")
-	}
-	fmt.Println("Running part", part)
-
-	starttime := time.Now()
-	if part == 1 {
-		ans := mixList(input, 1, 1)
-		//util.CopyToClipboard(fmt.Sprintf("%v", ans))
-		fmt.Println("Output:", ans)
-	} else {
-		ans := mixList(input, part2DecryptionKey, 10)
-		//util.CopyToClipboard(fmt.Sprintf("%v", ans))
-		fmt.Println("Output:", ans)
-	}
-	fmt.Printf("Took: %s\n", time.Since(starttime))
+// Solver solves the puzzle of day 20 using a linked list
+type Solver struct {
+	input string
+}
+
+func New() *Solver {
+	return &Solver{}
+}
+
+func (s *Solver) Parse(r io.Reader) (err error) {
+	s.input, err = readinput(r)
+	return err
+}
+
+func (s *Solver) Part1() any {
+	return mixList(s.input, 1, 1)
+}
+
+func (s *Solver) Part2() any {
+	return mixList(s.input, part2DecryptionKey, 10)
 }
 
 func ToInt(in string) int {
@@ -1,7 +1,10 @@
-package main
+package ll
 
 import (
 	"testing"
+
+	"github.com/jpcornet/AoC2022/aoc"
+	"github.com/jpcornet/AoC2022/aoc/aoctest"
 )
 
 var example = `1
@@ -98,3 +101,7 @@ func Test_llNode_move(t *testing.T) {
 		t.Errorf("moving 1, want %q got %q", want, got)
 	}
 }
+
+func BenchmarkSolver(b *testing.B) {
+	aoctest.Benchmark(b, func() aoc.Solver { return New() }, aoctest.BenchInput("../input"))
+}
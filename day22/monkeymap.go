@@ -1,11 +1,12 @@
-package main
+package day22
 
 import (
 	"fmt"
-	"os"
+	"io"
 	"strconv"
 	"strings"
-	"time"
+
+	"github.com/jpcornet/AoC2022/aoc"
 )
 
 type Field []string
@@ -35,21 +36,29 @@ func init_vars() {
 	}
 }
 
-func parse_input(filename string) (Field, Path) {
-	inbuf, err := os.ReadFile(filename)
+func parse_input(r io.Reader) (Field, Path, error) {
+	inbuf, err := io.ReadAll(r)
 	if err != nil {
-		panic(err)
+		return nil, nil, err
 	}
 	fieldstr, pathstr, ok := strings.Cut(string(inbuf[:]), "\n\n")
 	if !ok {
-		panic("Invalid input")
+		return nil, nil, &aoc.ParseError{Line: strings.Count(fieldstr, "\n") + 1, Msg: "no empty line between map and path"}
 	}
 	field := strings.Split(fieldstr, "\n")
 	if len(field[len(field)-1]) == 0 {
 		field = field[:len(field)-1]
 	}
+	for y, l := range field {
+		if x := strings.IndexFunc(l, func(r rune) bool { return r != ' ' && r != '.' && r != '#' }); x != -1 {
+			return nil, nil, &aoc.ParseError{Line: y + 1, Column: x + 1, Text: l[x:], Msg: "invalid character in map"}
+		}
+	}
+	// the path is on the line after the empty line
+	pathline := strings.Count(fieldstr, "\n") + 3
 	path := make(Path, 0, 10)
 	pathstr = strings.TrimRight(pathstr, "\n")
+	col := 0
 	for len(pathstr) > 0 {
 		var pe PathElem
 		lroffset := strings.IndexAny(pathstr, "LR")
@@ -64,7 +73,7 @@ func parse_input(filename string) (Field, Path) {
 			if i, err := strconv.Atoi(pathstr[:lroffset]); err == nil {
 				pe.dist = i
 			} else {
-				panic(fmt.Sprintf("Invalid input in path, not a number: %s (%v)", pathstr[:lroffset], err))
+				return nil, nil, &aoc.ParseError{Line: pathline, Column: col + 1, Text: pathstr[:lroffset], Msg: "invalid input in path, not a number", Err: err}
 			}
 		}
 		path = append(path, pe)
@@ -72,9 +81,10 @@ func parse_input(filename string) (Field, Path) {
 			break
 		} else {
 			pathstr = pathstr[lroffset+1:]
+			col += lroffset + 1
 		}
 	}
-	return field, path
+	return field, path, nil
 }
 
 func get_startpos(field Field) PosDir {
@@ -326,23 +336,33 @@ func to_pass(posd PosDir) int {
 	return 1000*(posd.pos[1]+1) + 4*(posd.pos[0]+1) + posd.dir
 }
 
-func main() {
-	if len(os.Args) != 2 {
-		panic("Provide input file")
-	}
-	starttime := time.Now()
-	field, path := parse_input(os.Args[1])
+// Solver solves the puzzle of day 22
+type Solver struct {
+	field    Field
+	path     Path
+	startpos PosDir
+}
+
+func New() *Solver {
+	return &Solver{}
+}
+
+func (s *Solver) Parse(r io.Reader) (err error) {
+	s.field, s.path, err = parse_input(r)
 	init_vars()
-	parsetime := time.Now()
-	startpos := get_startpos(field)
-	endpos := walk_path(field, path, startpos, make_basic_wrapper(field))
-	walktime := time.Now()
+	return err
+}
+
+func (s *Solver) Part1() any {
+	s.startpos = get_startpos(s.field)
+	endpos := walk_path(s.field, s.path, s.startpos, make_basic_wrapper(s.field))
 	fmt.Printf("endpos part 1: %v. Password: %d\n", endpos, to_pass(endpos))
-	cube_layout := analyze_cube(field)
-	endpos2 := walk_path(field, path, startpos, make_cube_wrapper(cube_layout))
-	walk2time := time.Now()
+	return to_pass(endpos)
+}
+
+func (s *Solver) Part2() any {
+	cube_layout := analyze_cube(s.field)
+	endpos2 := walk_path(s.field, s.path, s.startpos, make_cube_wrapper(cube_layout))
 	fmt.Printf("endpos part 2: %v, Password: %d\n", endpos2, to_pass(endpos2))
-	fmt.Printf("Parse took: %s\n", parsetime.Sub(starttime))
-	fmt.Printf("part 1 took: %s\n", walktime.Sub(parsetime))
-	fmt.Printf("part 2 took: %s\n", walk2time.Sub(walktime))
+	return to_pass(endpos2)
 }
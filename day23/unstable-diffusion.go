@@ -1,28 +1,39 @@
-package main
+package day23
 
 import (
+	"bufio"
 	"fmt"
-	"os"
+	"io"
 	"strings"
-	"time"
+
+	"github.com/jpcornet/AoC2022/aoc"
 )
 
 type Field [][]byte
 
-func parse_input(filename string) Field {
-	inbuf, err := os.ReadFile(filename)
-	if err != nil {
-		panic(err)
+func parse_input(r io.Reader) (Field, error) {
+	scanner := bufio.NewScanner(r)
+	bfield := make(Field, 0, 100)
+	for lnr := 0; scanner.Scan(); lnr++ {
+		line := scanner.Text()
+		if len(line) == 0 {
+			return nil, &aoc.ParseError{Line: lnr + 1, Msg: "empty row"}
+		}
+		if lnr > 0 && len(line) != len(bfield[0]) {
+			return nil, &aoc.ParseError{Line: lnr + 1, Text: line, Msg: fmt.Sprintf("row is %d long, the first row is %d", len(line), len(bfield[0]))}
+		}
+		if i := strings.IndexFunc(line, func(r rune) bool { return r != '.' && r != '#' }); i >= 0 {
+			return nil, &aoc.ParseError{Line: lnr + 1, Column: i + 1, Text: line[i : i+1], Msg: "the field has . and #"}
+		}
+		bfield = append(bfield, []byte(line))
 	}
-	field := strings.Split(string(inbuf[:]), "\n")
-	if len(field[len(field)-1]) == 0 {
-		field = field[:len(field)-1]
+	if err := scanner.Err(); err != nil {
+		return nil, err
 	}
-	bfield := make(Field, 0, len(field))
-	for _, s := range field {
-		bfield = append(bfield, []byte(s))
+	if len(bfield) == 0 {
+		return nil, &aoc.ParseError{Line: 1, Msg: "empty field"}
 	}
-	return bfield
+	return bfield, nil
 }
 
 func (field *Field) Expand() {
@@ -204,29 +215,43 @@ func (f Field) String() string {
 	return ret
 }
 
-func main() {
-	if len(os.Args) != 2 {
-		panic("Provide input file")
-	}
-	starttime := time.Now()
-	field := parse_input(os.Args[1])
-	parsetime := time.Now()
-	round := 1
-	field.Expand()
-	var part1 int
-	var part1time time.Time
-	for field.Evolve(round - 1) {
-		if round == 10 {
-			part1 = field.EmptyGround()
-			part1time = time.Now()
-			fmt.Printf("After round %d:\n%s\nEmpty ground: %d\n", round, field, part1)
-		}
-		field.Expand()
-		round++
-	}
-	fmt.Printf("Completed after round: %d\n%s", round, field)
-	part2time := time.Now()
-	fmt.Printf("Parse took: %s\n", parsetime.Sub(starttime))
-	fmt.Printf("part 1 took: %s\n", part1time.Sub(parsetime))
-	fmt.Printf("part 2 took: %s\n", part2time.Sub(part1time))
+// Solver solves the puzzle of day 23
+type Solver struct {
+	field Field
+	round int
+	done  bool
+}
+
+func New() *Solver {
+	return &Solver{}
+}
+
+func (s *Solver) Parse(r io.Reader) (err error) {
+	s.field, err = parse_input(r)
+	return err
+}
+
+func (s *Solver) Part1() any {
+	s.field.Expand()
+	for s.round = 1; s.round <= 10; s.round++ {
+		if !s.field.Evolve(s.round - 1) {
+			// no elf moves anymore, so this is also what the field looks like after round 10
+			s.done = true
+			break
+		}
+		s.field.Expand()
+	}
+	part1 := s.field.EmptyGround()
+	fmt.Printf("After round 10:\n%s\nEmpty ground: %d\n", s.field, part1)
+	return part1
+}
+
+func (s *Solver) Part2() any {
+	for !s.done && s.field.Evolve(s.round-1) {
+		s.field.Expand()
+		s.round++
+	}
+	s.done = true
+	fmt.Printf("Completed after round: %d\n%s", s.round, s.field)
+	return s.round
 }
@@ -1,10 +1,12 @@
-package main
+package day24
 
 import (
+	"bufio"
 	"fmt"
-	"os"
+	"io"
 	"strings"
-	"time"
+
+	"github.com/jpcornet/AoC2022/aoc"
 )
 
 type Pos struct{ x, y int }
@@ -41,30 +43,36 @@ func gcd(a, b int) int {
 	return a
 }
 
-func parse_input(filename string) Field {
-	inbuf, err := os.ReadFile(filename)
-	if err != nil {
-		panic(err)
+func parse_input(r io.Reader) (Field, error) {
+	scanner := bufio.NewScanner(r)
+	lines := make([]string, 0, 40)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return Field{}, err
 	}
-	lines := strings.Split(string(inbuf[:]), "\n")
-	if len(lines[len(lines)-1]) == 0 {
-		lines = lines[:len(lines)-1]
+	if len(lines) < 3 {
+		return Field{}, &aoc.ParseError{Line: len(lines) + 1, Msg: "need at least 3 lines for the field"}
 	}
 	var field Field
 	field.height = len(lines)
 	field.width = len(lines[0])
 	field.blizzards = make([]Blizzard, 0, 10)
 	for y, line := range lines {
+		if len(line) != field.width {
+			return Field{}, &aoc.ParseError{Line: y + 1, Text: line, Msg: fmt.Sprintf("expected line of width %d", field.width)}
+		}
 		if y == 0 || y == field.height-1 {
 			// expect the wall and the entry/exit
 			entrypos := strings.IndexByte(line, '.')
 			if entrypos < 0 {
-				panic("Invalid input")
+				return Field{}, &aoc.ParseError{Line: y + 1, Text: line, Msg: "no entry or exit in wall"}
 			}
 			// make sure we read a valid string
 			should_be := strings.Repeat("#", entrypos) + "." + strings.Repeat("#", field.width-entrypos-1)
 			if should_be != line {
-				panic(fmt.Sprintf("Invalid input. At line %d expected [%s] got [%s]", y, should_be, line))
+				return Field{}, &aoc.ParseError{Line: y + 1, Text: line, Msg: fmt.Sprintf("expected [%s]", should_be)}
 			}
 			if y == 0 {
 				field.in = Pos{entrypos, y}
@@ -75,14 +83,14 @@ func parse_input(filename string) Field {
 			for x, char := range line {
 				if x == 0 || x == field.width-1 {
 					if char != '#' {
-						panic(fmt.Sprintf("Expected wall at %d,%d got [%c]", x, y, char))
+						return Field{}, &aoc.ParseError{Line: y + 1, Column: x + 1, Text: string(char), Msg: "expected wall"}
 					}
 				} else if char == '#' {
-					panic(fmt.Sprintf("Unexpected wall at %d,%d", x, y))
+					return Field{}, &aoc.ParseError{Line: y + 1, Column: x + 1, Text: string(char), Msg: "unexpected wall"}
 				} else if char != '.' {
 					dir := Direction(strings.IndexByte(">v<^", byte(char)))
 					if dir == Direction(-1) {
-						panic(fmt.Sprintf("Unexpected character at %d,%d: [%c]", x, y, char))
+						return Field{}, &aoc.ParseError{Line: y + 1, Column: x + 1, Text: string(char), Msg: "unexpected character"}
 					}
 					field.blizzards = append(field.blizzards, Blizzard{pos: Pos{x, y}, dir: dir})
 				}
@@ -91,7 +99,7 @@ func parse_input(filename string) Field {
 	}
 	// height and width include the walls on all sides, so the field is 2 smaller
 	field.lcm = (field.height - 2) * (field.width - 2) / gcd(field.height-2, field.width-2)
-	return field
+	return field, nil
 }
 
 type Dir struct{ dx, dy int }
@@ -183,23 +191,33 @@ func walk_path(f *Field, start, finish Pos) (int, Path) {
 	}
 }
 
-func main() {
-	if len(os.Args) != 2 {
-		panic("Provide input file")
-	}
-	starttime := time.Now()
-	field := parse_input(os.Args[1])
-	parsetime := time.Now()
-	steps, path := walk_path(&field, field.in, field.out)
-	part1time := time.Now()
-	steps2, path2 := walk_path(&field, field.out, field.in)
-	steps3, path3 := walk_path(&field, field.in, field.out)
-	part2time := time.Now()
-	fmt.Printf("part 1 Steps: %d , Path taken: %v\n", steps, path)
+// Solver solves the puzzle of day 24
+type Solver struct {
+	field Field
+	steps int
+}
+
+func New() *Solver {
+	return &Solver{}
+}
+
+func (s *Solver) Parse(r io.Reader) (err error) {
+	s.field, err = parse_input(r)
+	return err
+}
+
+func (s *Solver) Part1() any {
+	var path Path
+	s.steps, path = walk_path(&s.field, s.field.in, s.field.out)
+	fmt.Printf("part 1 Steps: %d , Path taken: %v\n", s.steps, path)
+	return s.steps
+}
+
+// Part2 continues where part 1 left off, so the blizzards are in the right position
+func (s *Solver) Part2() any {
+	steps2, path2 := walk_path(&s.field, s.field.out, s.field.in)
+	steps3, path3 := walk_path(&s.field, s.field.in, s.field.out)
 	fmt.Printf("part 2 going back, steps: %d, path taken: %v\n", steps2, path2)
 	fmt.Printf("part 2 return, steps: %d, path taken: %v\n", steps3, path3)
-	fmt.Printf("part 2 total time: %d\n", steps+steps2+steps3)
-	fmt.Printf("Parse took: %s\n", parsetime.Sub(starttime))
-	fmt.Printf("part 1 took: %s\n", part1time.Sub(parsetime))
-	fmt.Printf("part 2 took: %s\n", part2time.Sub(part1time))
+	return s.steps + steps2 + steps3
 }
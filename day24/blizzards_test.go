@@ -0,0 +1,43 @@
+package day24
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jpcornet/AoC2022/aoc"
+	"github.com/jpcornet/AoC2022/aoc/aoctest"
+)
+
+func Test_parse_input_errors(t *testing.T) {
+	tests := []struct {
+		name         string
+		input        string
+		line, column int
+		text         string
+	}{
+		{name: "too few lines", input: "#.#\n#.#\n", line: 3},
+		{name: "other width", input: "#.###\n#..#\n###.#\n", line: 2, text: "#..#"},
+		{name: "no entry", input: "#####\n#...#\n###.#\n", line: 1, text: "#####"},
+		{name: "two exits", input: "#.###\n#...#\n#.#.#\n", line: 3, text: "#.#.#"},
+		{name: "no wall", input: "#.###\n..>.#\n###.#\n", line: 2, column: 1, text: "."},
+		{name: "wall inside", input: "#.###\n#.#.#\n###.#\n", line: 2, column: 3, text: "#"},
+		{name: "other character", input: "#.###\n#.x.#\n###.#\n", line: 2, column: 3, text: "x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parse_input(strings.NewReader(tt.input))
+			var perr *aoc.ParseError
+			if !errors.As(err, &perr) {
+				t.Fatalf("parse_input() error = %v, want a ParseError", err)
+			}
+			if perr.Line != tt.line || perr.Column != tt.column || perr.Text != tt.text {
+				t.Errorf("parse_input() error at %d:%d [%s], want %d:%d [%s]", perr.Line, perr.Column, perr.Text, tt.line, tt.column, tt.text)
+			}
+		})
+	}
+}
+
+func BenchmarkSolver(b *testing.B) {
+	aoctest.Benchmark(b, func() aoc.Solver { return New() }, aoctest.BenchInput("input"))
+}
@@ -0,0 +1,3 @@
+module github.com/jpcornet/AoC2022
+
+go 1.21
@@ -0,0 +1,232 @@
+package voxel
+
+import "sort"
+
+// When even the merged cells are too many, the space is split in boxes instead. A tree splits the space in two, again
+// and again, until every box is empty or holds one cube. The number of boxes grows with the number of cubes, not with
+// the space between them.
+
+// positions from lo up to hi, without hi, and what is in all of them
+type box struct {
+	lo, hi Cube
+	v      uint8
+}
+
+// a node of the tree splits at a coordinate on an axis: below it is left, the rest is right. A leaf is one box.
+type node struct {
+	axis, at    int
+	left, right int
+	leaf        int // index of the box, -1 if this is not a leaf
+}
+
+type tree struct {
+	solid  Set
+	all    box // the cubes with a layer of air around them, beyond it everything is outside
+	nodes  []node
+	boxes  []box
+	links  [][]int // for each box, the boxes the air can squeeze to between two cubes that share an edge
+	linked bool
+}
+
+func new_tree(cubes []Cube, lo, hi Cube) *tree {
+	t := &tree{solid: NewSet(cubes)}
+	t.all.lo, t.all.hi = lo.Add(Cube{-1, -1, -1}), hi.Add(Cube{2, 2, 2})
+	unique := make([]Cube, 0, len(t.solid))
+	for c := range t.solid {
+		unique = append(unique, c)
+	}
+	t.build(t.all.lo, t.all.hi, unique)
+	return t
+}
+
+// split the space from lo to hi until every box is empty or one cube, returns the node
+func (t *tree) build(lo, hi Cube, cubes []Cube) int {
+	n := len(t.nodes)
+	t.nodes = append(t.nodes, node{leaf: -1})
+	if len(cubes) == 0 || (len(cubes) == 1 && hi == cubes[0].Add(Cube{1, 1, 1}) && lo == cubes[0]) {
+		v := Air
+		if len(cubes) == 1 {
+			v = Solid
+		}
+		t.nodes[n].leaf = len(t.boxes)
+		t.boxes = append(t.boxes, box{lo: lo, hi: hi, v: v})
+		return n
+	}
+	// split at the middle cube on the axis where they are spread the most, or cut a single cube out of the space
+	axis, spread := 0, -1
+	for a := range lo {
+		first, last := cubes[0][a], cubes[0][a]
+		for _, c := range cubes {
+			first, last = min(first, c[a]), max(last, c[a])
+		}
+		if last-first > spread && (len(cubes) > 1 || hi[a]-lo[a] > 1) {
+			axis, spread = a, last-first
+		}
+	}
+	var at int
+	if spread > 0 {
+		sort.Slice(cubes, func(i, j int) bool { return cubes[i][axis] < cubes[j][axis] })
+		at = cubes[len(cubes)/2][axis]
+		if at == cubes[0][axis] {
+			at++
+		}
+	} else if c := cubes[0]; c[axis] > lo[axis] {
+		at = c[axis]
+	} else {
+		at = c[axis] + 1
+	}
+	split := sort.Search(len(cubes), func(i int) bool { return cubes[i][axis] >= at })
+	if spread <= 0 {
+		split = 0
+		if cubes[0][axis] < at {
+			split = 1
+		}
+	}
+	left_hi, right_lo := hi, lo
+	left_hi[axis], right_lo[axis] = at, at
+	left := t.build(lo, left_hi, cubes[:split])
+	right := t.build(right_lo, hi, cubes[split:])
+	t.nodes[n] = node{axis: axis, at: at, left: left, right: right, leaf: -1}
+	return n
+}
+
+// call fn for every box that overlaps the positions from lo up to hi
+func (t *tree) find(n int, lo, hi Cube, fn func(b int)) {
+	nd := t.nodes[n]
+	if nd.leaf >= 0 {
+		fn(nd.leaf)
+		return
+	}
+	if lo[nd.axis] < nd.at {
+		t.find(nd.left, lo, hi, fn)
+	}
+	if hi[nd.axis] > nd.at {
+		t.find(nd.right, lo, hi, fn)
+	}
+}
+
+func (t *tree) contains(c Cube) bool {
+	for a := range c {
+		if c[a] < t.all.lo[a] || c[a] >= t.all.hi[a] {
+			return false
+		}
+	}
+	return true
+}
+
+// the box the position is in, -1 if it is beyond all boxes
+func (t *tree) box_at(c Cube) int {
+	if !t.contains(c) {
+		return -1
+	}
+	n := 0
+	for t.nodes[n].leaf < 0 {
+		if c[t.nodes[n].axis] < t.nodes[n].at {
+			n = t.nodes[n].left
+		} else {
+			n = t.nodes[n].right
+		}
+	}
+	return t.nodes[n].leaf
+}
+
+// what is at the position
+func (t *tree) at(c Cube) uint8 {
+	b := t.box_at(c)
+	if b < 0 {
+		return Outside
+	}
+	return t.boxes[b].v
+}
+
+// call fn for every box next to b in the direction
+func (t *tree) next_to(b int, d Cube, fn func(b int)) {
+	lo, hi := t.boxes[b].lo, t.boxes[b].hi
+	for a := range d {
+		switch {
+		case d[a] > 0:
+			lo[a], hi[a] = hi[a], hi[a]+1
+		case d[a] < 0:
+			lo[a], hi[a] = lo[a]-1, lo[a]
+		}
+		if lo[a] < t.all.lo[a] || hi[a] > t.all.hi[a] {
+			return
+		}
+	}
+	t.find(0, lo, hi, fn)
+}
+
+// Moving diagonally only makes a difference when the air squeezes between two cubes that share an edge and are not
+// connected. Anywhere else the air can go around through cells that share a face.
+func (t *tree) link(rules Rules) {
+	t.linked = true
+	if rules.Air < 18 || rules.Solid > 6 {
+		return
+	}
+	t.links = make([][]int, len(t.boxes))
+	for c := range t.solid {
+		for p := range c {
+			for q := range c {
+				if p == q {
+					continue
+				}
+				for _, sp := range []int{-1, 1} {
+					for _, sq := range []int{-1, 1} {
+						// the cube that shares an edge with c, and the air on both sides of the edge
+						other, a, b := c, c, c
+						other[p] += sp
+						other[q] += sq
+						a[p] += sp
+						b[q] += sq
+						if !t.solid[other] || t.solid[a] || t.solid[b] {
+							continue
+						}
+						if ba, bb := t.box_at(a), t.box_at(b); ba >= 0 && bb >= 0 {
+							t.links[ba] = append(t.links[ba], bb)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// Flood the air from box start, marking it as v. If set, calls found for every box marked, and touch for every face
+// of a cube next to it.
+func (t *tree) flood(start int, v uint8, rules Rules, found func(b box), touch func()) {
+	if !t.linked {
+		t.link(rules)
+	}
+	t.boxes[start].v = v
+	queue := []int{start}
+	visit := func(next int) {
+		if t.boxes[next].v == Air {
+			t.boxes[next].v = v
+			queue = append(queue, next)
+		}
+	}
+	for len(queue) > 0 {
+		b := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+		if found != nil {
+			found(t.boxes[b])
+		}
+		for _, d := range Directions {
+			t.next_to(b, d, func(next int) {
+				if t.boxes[next].v == Solid {
+					if touch != nil {
+						// a cube is a box of its own, it touches with one face
+						touch()
+					}
+					return
+				}
+				visit(next)
+			})
+		}
+		if t.links != nil {
+			for _, next := range t.links[b] {
+				visit(next)
+			}
+		}
+	}
+}
@@ -0,0 +1,443 @@
+package voxel
+
+import (
+	"fmt"
+	"sort"
+)
+
+// What is in a cell of a grid
+const (
+	Air     uint8 = iota // air that is not known to be outside
+	Solid                // one of the cubes
+	Outside              // air that is connected to the outside
+	Pocket               // air that is enclosed by the cubes, after Pockets
+)
+
+// grids up to this many cells keep them in a slice
+const max_dense = 1 << 27
+
+// Grid holds what is in every cell of the bounding box of the cubes. Around the cubes is a layer of air, and
+// around that a layer that is marked as outside, so walking from a cube or the air never leaves the grid.
+// If the bounding box is too big, the cells between the coordinates of the cubes are merged: on every axis a cell
+// goes up to the next coordinate that is used. If there are still too many cells, the space is split in boxes that
+// are empty or one cube.
+type Grid struct {
+	lo      Cube     // coordinate of the first cell
+	size    [3]int   // number of cells on each axis
+	axes    [3][]int // if the cells are merged, the first coordinate of each cell on each axis
+	dense   []uint8
+	boxes   *tree // instead of the cells
+	rules   Rules
+	filled  bool        // the outside has been flooded
+	pockets []AirPocket // the pockets found, sorted
+	found   bool        // the pockets have been found
+}
+
+// NewGrid makes a grid of the cubes
+func NewGrid(cubes []Cube) *Grid {
+	return new_grid(cubes, max_dense)
+}
+
+// a grid with at most dense_cells cells in a slice
+func new_grid(cubes []Cube, dense_cells int) *Grid {
+	g := &Grid{rules: Rules{Air: 6, Solid: 26}}
+	var lo, hi Cube
+	if len(cubes) > 0 {
+		lo, hi = cubes[0], cubes[0]
+	}
+	for _, c := range cubes {
+		for a := range c {
+			lo[a] = min(lo[a], c[a])
+			hi[a] = max(hi[a], c[a])
+		}
+	}
+	for a := range lo {
+		g.lo[a] = lo[a] - 2
+		g.size[a] = hi[a] - lo[a] + 5
+	}
+	if g.cells() > dense_cells {
+		g.merge_cells(cubes, hi)
+	}
+	if g.cells() > dense_cells {
+		g.boxes = new_tree(cubes, lo, hi)
+		return g
+	}
+	g.dense = make([]uint8, g.cells())
+	g.mark_border()
+	for _, c := range cubes {
+		g.set(g.index(c), Solid)
+	}
+	return g
+}
+
+// number of cells, or more than max_dense
+func (g *Grid) cells() int {
+	cells := 1
+	for _, size := range g.size {
+		if size > max_dense {
+			return max_dense + 1
+		}
+		cells = min(cells*size, max_dense+1)
+	}
+	return cells
+}
+
+// on each axis, keep the coordinates of the cubes and the ones just after them, the rest is merged
+func (g *Grid) merge_cells(cubes []Cube, hi Cube) {
+	for a := range g.axes {
+		seen := map[int]bool{g.lo[a]: true, g.lo[a] + 1: true, hi[a] + 2: true}
+		for _, c := range cubes {
+			seen[c[a]] = true
+			seen[c[a]+1] = true
+		}
+		axis := make([]int, 0, len(seen))
+		for coord := range seen {
+			axis = append(axis, coord)
+		}
+		sort.Ints(axis)
+		g.axes[a] = axis
+		g.size[a] = len(axis)
+	}
+}
+
+// mark the outer layer of cells as outside
+func (g *Grid) mark_border() {
+	for a := range g.size {
+		b, c := (a+1)%3, (a+2)%3
+		for _, edge := range []int{0, g.size[a] - 1} {
+			var i Cube
+			i[a] = edge
+			for i[b] = 0; i[b] < g.size[b]; i[b]++ {
+				for i[c] = 0; i[c] < g.size[c]; i[c]++ {
+					g.set(i, Outside)
+				}
+			}
+		}
+	}
+}
+
+// the cell the position is in, counted from the first cell on each axis
+func (g *Grid) index(c Cube) Cube {
+	var i Cube
+	for a := range c {
+		if g.axes[a] == nil {
+			i[a] = c[a] - g.lo[a]
+		} else {
+			// the last cell that starts at or before the coordinate
+			i[a] = sort.SearchInts(g.axes[a], c[a]+1) - 1
+		}
+	}
+	return i
+}
+
+// the first position in the cell with that index, and the number of positions on each axis
+func (g *Grid) extent(i Cube) (Cube, Cube) {
+	var first, size Cube
+	for a := range i {
+		if g.axes[a] == nil {
+			first[a], size[a] = g.lo[a]+i[a], 1
+		} else if i[a] == len(g.axes[a])-1 {
+			first[a], size[a] = g.axes[a][i[a]], 1
+		} else {
+			first[a], size[a] = g.axes[a][i[a]], g.axes[a][i[a]+1]-g.axes[a][i[a]]
+		}
+	}
+	return first, size
+}
+
+func (g *Grid) inside(i Cube) bool {
+	return i[0] >= 0 && i[1] >= 0 && i[2] >= 0 && i[0] < g.size[0] && i[1] < g.size[1] && i[2] < g.size[2]
+}
+
+// the position of the cell in the dense slice
+func (g *Grid) offset(i Cube) int {
+	return (i[0]*g.size[1]+i[1])*g.size[2] + i[2]
+}
+
+// the distance in the dense slice to the next cell in each of the directions
+func (g *Grid) strides() [len(Directions)]int {
+	var strides [len(Directions)]int
+	for dir, d := range Directions {
+		strides[dir] = g.offset(d)
+	}
+	return strides
+}
+
+// what is in the cell with that index. Everything beyond the grid is outside.
+func (g *Grid) get(i Cube) uint8 {
+	if !g.inside(i) {
+		return Outside
+	}
+	return g.dense[g.offset(i)]
+}
+
+func (g *Grid) set(i Cube, v uint8) {
+	g.dense[g.offset(i)] = v
+}
+
+// At is what is at the position
+func (g *Grid) At(c Cube) uint8 {
+	if g.boxes != nil {
+		return g.boxes.at(c)
+	}
+	return g.get(g.index(c))
+}
+
+// Rules says how the air can move: to the 6 cells that share a face, the 18 that also share an edge, or all 26 around it.
+// Moving diagonally it squeezes between cubes, that is not possible if those cubes are connected. Solid says when cubes
+// are connected: 6 if they share a face, 18 also if they share an edge, 26 also if they share a corner.
+type Rules struct {
+	Air, Solid int
+}
+
+// SetRules changes how the air moves, before FillOutside or Pockets. The default is Air 6, Solid 26.
+func (g *Grid) SetRules(r Rules) error {
+	for _, n := range []int{r.Air, r.Solid} {
+		if n != 6 && n != 18 && n != 26 {
+			return fmt.Errorf("neighbourhood must be 6, 18 or 26, not %d", n)
+		}
+	}
+	if g.filled {
+		return fmt.Errorf("the outside has already been filled")
+	}
+	g.rules = r
+	return nil
+}
+
+// one way the air can move
+type move struct {
+	d Cube
+	// for a diagonal move, the cells around the line it moves along, in order around it
+	around []Cube
+}
+
+// the ways the air can move with the rules
+func (g *Grid) moves() []move {
+	var moves []move
+	for x := -1; x <= 1; x++ {
+		for y := -1; y <= 1; y++ {
+			for z := -1; z <= 1; z++ {
+				m := move{d: Cube{x, y, z}}
+				var axes []Cube
+				for a := range m.d {
+					if m.d[a] != 0 {
+						var step Cube
+						step[a] = m.d[a]
+						axes = append(axes, step)
+					}
+				}
+				switch {
+				case len(axes) == 0 || (len(axes) == 2 && g.rules.Air < 18) || (len(axes) == 3 && g.rules.Air < 26):
+					continue
+				case len(axes) == 2:
+					m.around = axes
+				case len(axes) == 3:
+					// through a corner, the cells that are 1 and 2 steps away in turn
+					for i, step := range axes {
+						m.around = append(m.around, step, step.Add(axes[(i+1)%3]))
+					}
+				}
+				moves = append(moves, m)
+			}
+		}
+	}
+	return moves
+}
+
+// a diagonal move is blocked if the cubes around it are connected all the way around
+func (g *Grid) blocked(m move, solid func(k int) bool) bool {
+	switch len(m.around) {
+	case 2:
+		// two cubes that share an edge
+		return g.rules.Solid >= 18 && solid(0) && solid(1)
+	case 6:
+		// going around, the cubes can be this far apart and still be connected
+		step := map[int]int{6: 1, 18: 2, 26: 3}[g.rules.Solid]
+		first, last := -1, -1
+		for k := range m.around {
+			if !solid(k) {
+				continue
+			}
+			if first < 0 {
+				first = k
+			} else if k-last > step {
+				return false
+			}
+			last = k
+		}
+		return first >= 0 && first+len(m.around)-last <= step
+	}
+	return false
+}
+
+// Flood the air from start, marking it as v. If set, calls found for every cell marked, and touch for every face of a
+// cube next to it.
+func (g *Grid) flood(start Cube, v uint8, found func(i Cube), touch func()) {
+	moves := g.moves()
+	g.set(start, v)
+	if found == nil {
+		// the border is outside, so the flood never gets there and every neighbour is in the slice
+		strides := make([]int, len(moves))
+		around := make([][]int, len(moves))
+		for n, m := range moves {
+			strides[n] = g.offset(m.d)
+			for _, c := range m.around {
+				around[n] = append(around[n], g.offset(c))
+			}
+		}
+		queue := []int{g.offset(start)}
+		for len(queue) > 0 {
+			pos := queue[len(queue)-1]
+			queue = queue[:len(queue)-1]
+			for n, stride := range strides {
+				if g.dense[pos+stride] != Air {
+					continue
+				}
+				if around[n] != nil && g.blocked(moves[n], func(k int) bool { return g.dense[pos+around[n][k]] == Solid }) {
+					continue
+				}
+				g.dense[pos+stride] = v
+				queue = append(queue, pos+stride)
+			}
+		}
+		return
+	}
+	queue := []Cube{start}
+	for len(queue) > 0 {
+		i := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+		if found != nil {
+			found(i)
+		}
+		for _, d := range Directions {
+			if touch != nil && g.get(i.Add(d)) == Solid {
+				touch()
+			}
+		}
+		for _, m := range moves {
+			next := i.Add(m.d)
+			if !g.inside(next) || g.get(next) != Air {
+				continue
+			}
+			if m.around != nil && g.blocked(m, func(k int) bool { return g.get(i.Add(m.around[k])) == Solid }) {
+				continue
+			}
+			g.set(next, v)
+			queue = append(queue, next)
+		}
+	}
+}
+
+// FillOutside marks all air connected to the outside, with a single flood fill from the layer of air around the cubes
+func (g *Grid) FillOutside() {
+	if g.filled {
+		return
+	}
+	g.filled = true
+	if g.boxes != nil {
+		g.boxes.flood(g.boxes.box_at(g.boxes.all.lo), Outside, g.rules, nil, nil)
+		return
+	}
+	g.flood(Cube{1, 1, 1}, Outside, nil, nil)
+}
+
+// Surface counts the faces of the cubes that do not touch another cube. With outside set, only the faces that
+// touch air connected to the outside are counted, after FillOutside.
+func (g *Grid) Surface(cubes []Cube, outside bool) int {
+	surface := 0
+	count := func(v uint8) {
+		if v == Outside || (v != Solid && !outside) {
+			surface++
+		}
+	}
+	if g.boxes != nil {
+		for _, c := range cubes {
+			for _, d := range Directions {
+				count(g.boxes.at(c.Add(d)))
+			}
+		}
+		return surface
+	}
+	strides := g.strides()
+	for _, c := range cubes {
+		pos := g.offset(g.index(c))
+		for _, stride := range strides {
+			count(g.dense[pos+stride])
+		}
+	}
+	return surface
+}
+
+// AirPocket is air enclosed by the cubes, not connected to the outside
+type AirPocket struct {
+	Volume   int  // number of positions
+	Min, Max Cube // bounding box
+	Surface  int  // number of faces of the cubes that touch the pocket
+}
+
+// Pockets finds every pocket of air inside the cubes, the biggest first
+func (g *Grid) Pockets(cubes []Cube) []AirPocket {
+	if g.found {
+		return g.pockets
+	}
+	g.found = true
+	g.FillOutside()
+	// every pocket touches a cube, the air that is left next to a cube is in a pocket
+	for _, c := range cubes {
+		for _, d := range Directions {
+			if g.boxes != nil {
+				if start := g.boxes.box_at(c.Add(d)); start >= 0 && g.boxes.boxes[start].v == Air {
+					g.pockets = append(g.pockets, g.fill_box_pocket(start))
+				}
+			} else if start := g.index(c).Add(d); g.get(start) == Air {
+				g.pockets = append(g.pockets, g.fill_pocket(start))
+			}
+		}
+	}
+	sort.Slice(g.pockets, func(i, j int) bool {
+		a, b := g.pockets[i], g.pockets[j]
+		if a.Volume != b.Volume {
+			return a.Volume > b.Volume
+		}
+		if a.Surface != b.Surface {
+			return a.Surface > b.Surface
+		}
+		return less(a.Min, b.Min)
+	})
+	return g.pockets
+}
+
+// add the positions from first, size on each axis, to the pocket
+func (p *AirPocket) add(first, size Cube) {
+	if p.Volume == 0 {
+		p.Min, p.Max = first, first
+	}
+	p.Volume += size[0] * size[1] * size[2]
+	for a := range first {
+		p.Min[a] = min(p.Min[a], first[a])
+		p.Max[a] = max(p.Max[a], first[a]+size[a]-1)
+	}
+}
+
+// mark the air connected to start as a pocket
+func (g *Grid) fill_pocket(start Cube) AirPocket {
+	var p AirPocket
+	g.flood(start, Pocket, func(i Cube) {
+		p.add(g.extent(i))
+	}, func() {
+		// a cube is always a single cell, so it touches the pocket with one face
+		p.Surface++
+	})
+	return p
+}
+
+// mark the air connected to box start as a pocket
+func (g *Grid) fill_box_pocket(start int) AirPocket {
+	var p AirPocket
+	g.boxes.flood(start, Pocket, g.rules, func(b box) {
+		p.add(b.lo, b.hi.Add(Cube{-b.lo[0], -b.lo[1], -b.lo[2]}))
+	}, func() {
+		p.Surface++
+	})
+	return p
+}
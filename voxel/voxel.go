@@ -0,0 +1,176 @@
+// Package voxel finds the surface of a shape made of unit cubes, and writes it as a mesh for 3D viewers.
+package voxel
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Cube is the cube from x,y,z to x+1,y+1,z+1
+type Cube [3]int
+
+// Directions are the 6 directions a face of a cube can point to
+var Directions = [6]Cube{
+	{1, 0, 0},
+	{-1, 0, 0},
+	{0, 1, 0},
+	{0, -1, 0},
+	{0, 0, 1},
+	{0, 0, -1},
+}
+
+// Add returns the cube moved by d
+func (c Cube) Add(d Cube) Cube {
+	return Cube{c[0] + d[0], c[1] + d[1], c[2] + d[2]}
+}
+
+// Face is one side of a cube, Dir is the index in Directions it points to
+type Face struct {
+	Cube Cube
+	Dir  int
+}
+
+// Normal is the direction the face points to
+func (f Face) Normal() Cube {
+	return Directions[f.Dir]
+}
+
+// Corners are the corners of the face, counter clockwise when looking at it from outside the cube
+func (f Face) Corners() [4][3]int {
+	normal := f.Normal()
+	// the axis the face is perpendicular to, and the two other axes in cyclic order
+	a := 0
+	for normal[a] == 0 {
+		a++
+	}
+	b, c := (a+1)%3, (a+2)%3
+	steps := [4][2]int{{0, 0}, {1, 0}, {1, 1}, {0, 1}}
+	if normal[a] < 0 {
+		steps = [4][2]int{{0, 0}, {0, 1}, {1, 1}, {1, 0}}
+	}
+	var corners [4][3]int
+	for i, step := range steps {
+		corners[i] = f.Cube
+		if normal[a] > 0 {
+			corners[i][a]++
+		}
+		corners[i][b] += step[0]
+		corners[i][c] += step[1]
+	}
+	return corners
+}
+
+// Set is a shape made of cubes
+type Set map[Cube]bool
+
+// NewSet makes a shape of the cubes
+func NewSet(cubes []Cube) Set {
+	set := make(Set, len(cubes))
+	for _, c := range cubes {
+		set[c] = true
+	}
+	return set
+}
+
+// Surface is the number of faces of a cube that do not touch another cube
+func (s Set) Surface() int {
+	surface := 0
+	for c := range s {
+		for _, d := range Directions {
+			if !s[c.Add(d)] {
+				surface++
+			}
+		}
+	}
+	return surface
+}
+
+// Faces returns every face of a cube that does not touch another cube, sorted by cube and direction
+func (s Set) Faces() []Face {
+	var faces []Face
+	for c := range s {
+		for dir, d := range Directions {
+			if !s[c.Add(d)] {
+				faces = append(faces, Face{Cube: c, Dir: dir})
+			}
+		}
+	}
+	sort.Slice(faces, func(i, j int) bool {
+		if faces[i].Cube != faces[j].Cube {
+			return less(faces[i].Cube, faces[j].Cube)
+		}
+		return faces[i].Dir < faces[j].Dir
+	})
+	return faces
+}
+
+func less(a, b Cube) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return false
+}
+
+// Mesh is a surface made of square faces, that share their corners
+type Mesh struct {
+	Vertices [][3]int
+	Quads    [][4]int // indexes in Vertices, counter clockwise seen from outside
+	Normals  []Cube   // the direction each quad points to
+}
+
+// NewMesh makes a mesh of the faces, every corner is only once in the vertices
+func NewMesh(faces []Face) Mesh {
+	var m Mesh
+	index := make(map[[3]int]int)
+	for _, f := range faces {
+		var quad [4]int
+		for i, corner := range f.Corners() {
+			vnr, ok := index[corner]
+			if !ok {
+				vnr = len(m.Vertices)
+				index[corner] = vnr
+				m.Vertices = append(m.Vertices, corner)
+			}
+			quad[i] = vnr
+		}
+		m.Quads = append(m.Quads, quad)
+		m.Normals = append(m.Normals, f.Normal())
+	}
+	return m
+}
+
+// WriteOBJ writes the mesh in the Wavefront OBJ format
+func (m Mesh) WriteOBJ(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, v := range m.Vertices {
+		fmt.Fprintf(bw, "v %d %d %d\n", v[0], v[1], v[2])
+	}
+	// vertices are numbered from 1
+	for _, q := range m.Quads {
+		fmt.Fprintf(bw, "f %d %d %d %d\n", q[0]+1, q[1]+1, q[2]+1, q[3]+1)
+	}
+	return bw.Flush()
+}
+
+// WriteSTL writes the mesh in the ASCII STL format, every quad as 2 triangles
+func (m Mesh) WriteSTL(w io.Writer, name string) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "solid %s\n", name)
+	for i, q := range m.Quads {
+		n := m.Normals[i]
+		for _, tri := range [2][3]int{{q[0], q[1], q[2]}, {q[0], q[2], q[3]}} {
+			fmt.Fprintf(bw, "facet normal %d %d %d\n outer loop\n", n[0], n[1], n[2])
+			for _, vnr := range tri {
+				v := m.Vertices[vnr]
+				fmt.Fprintf(bw, "  vertex %d %d %d\n", v[0], v[1], v[2])
+			}
+			fmt.Fprintf(bw, " endloop\nendfacet\n")
+		}
+	}
+	fmt.Fprintf(bw, "endsolid %s\n", name)
+	return bw.Flush()
+}
@@ -0,0 +1,262 @@
+package voxel
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_mesh(t *testing.T) {
+	tests := []struct {
+		name     string
+		cubes    []Cube
+		faces    int
+		vertices int
+	}{
+		{name: "one cube", cubes: []Cube{{1, 1, 1}}, faces: 6, vertices: 8},
+		{name: "two cubes", cubes: []Cube{{1, 1, 1}, {2, 1, 1}}, faces: 10, vertices: 12},
+		{name: "touching edges", cubes: []Cube{{0, 0, 0}, {1, 1, 0}}, faces: 12, vertices: 14},
+		{name: "hollow", cubes: hollow_cube(), faces: 6*9 + 6, vertices: 56 + 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set := NewSet(tt.cubes)
+			faces := set.Faces()
+			if len(faces) != tt.faces || set.Surface() != tt.faces {
+				t.Errorf("Faces() = %d faces, Surface() = %d, want %d", len(faces), set.Surface(), tt.faces)
+			}
+			m := NewMesh(faces)
+			if len(m.Vertices) != tt.vertices {
+				t.Errorf("NewMesh() has %d vertices, want %d", len(m.Vertices), tt.vertices)
+			}
+			// every quad points outward
+			for i, q := range m.Quads {
+				a, b, c := m.Vertices[q[0]], m.Vertices[q[1]], m.Vertices[q[2]]
+				u := [3]int{b[0] - a[0], b[1] - a[1], b[2] - a[2]}
+				v := [3]int{c[0] - a[0], c[1] - a[1], c[2] - a[2]}
+				cross := Cube{u[1]*v[2] - u[2]*v[1], u[2]*v[0] - u[0]*v[2], u[0]*v[1] - u[1]*v[0]}
+				if cross != m.Normals[i] {
+					t.Errorf("quad %d of %v has normal %v, want %v", i, faces[i], cross, m.Normals[i])
+				}
+			}
+			// the surface is closed: every edge is walked as often in one direction as in the other
+			edges := make(map[[2]int]int)
+			for _, q := range m.Quads {
+				for i := range q {
+					edges[[2]int{q[i], q[(i+1)%4]}]++
+					edges[[2]int{q[(i+1)%4], q[i]}]--
+				}
+			}
+			for edge, count := range edges {
+				if count != 0 {
+					t.Errorf("edge %v is not closed", edge)
+				}
+			}
+		})
+	}
+}
+
+// a 3x3x3 cube without the middle
+func hollow_cube() []Cube {
+	var cubes []Cube
+	for x := 0; x < 3; x++ {
+		for y := 0; y < 3; y++ {
+			for z := 0; z < 3; z++ {
+				if x != 1 || y != 1 || z != 1 {
+					cubes = append(cubes, Cube{x, y, z})
+				}
+			}
+		}
+	}
+	return cubes
+}
+
+func Test_write(t *testing.T) {
+	m := NewMesh(NewSet([]Cube{{0, 0, 0}}).Faces())
+	var obj bytes.Buffer
+	if err := m.WriteOBJ(&obj); err != nil {
+		t.Fatal(err)
+	}
+	if v, f := strings.Count(obj.String(), "v "), strings.Count(obj.String(), "f "); v != 8 || f != 6 {
+		t.Errorf("WriteOBJ() has %d vertices and %d faces, want 8 and 6", v, f)
+	}
+	if !strings.Contains(obj.String(), "f 1 2 3 4\n") {
+		t.Errorf("WriteOBJ() does not number vertices from 1:\n%s", obj.String())
+	}
+	var stl bytes.Buffer
+	if err := m.WriteSTL(&stl, "cube"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(stl.String(), "solid cube\n") || !strings.HasSuffix(stl.String(), "endsolid cube\n") {
+		t.Errorf("WriteSTL() is not a solid named cube")
+	}
+	if n := strings.Count(stl.String(), "endfacet"); n != 12 {
+		t.Errorf("WriteSTL() has %d triangles, want 12", n)
+	}
+}
+
+func Test_grid(t *testing.T) {
+	// the hollow cube, another cube far away, and a ring that is open at the top and the bottom
+	cubes := append(hollow_cube(), Cube{100, -200, 1})
+	for x := 10; x < 13; x++ {
+		for y := 0; y < 3; y++ {
+			if x != 11 || y != 1 {
+				cubes = append(cubes, Cube{x, y, 0}, Cube{x, y, 1})
+			}
+		}
+	}
+	tests := []struct {
+		name        string
+		dense_cells int
+		merged      bool
+		boxes       bool
+	}{
+		{name: "dense", dense_cells: max_dense},
+		{name: "merged", dense_cells: 10000, merged: true},
+		{name: "boxes", dense_cells: 100, merged: true, boxes: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := new_grid(cubes, tt.dense_cells)
+			if merged, boxes := g.axes[0] != nil, g.boxes != nil; merged != tt.merged || boxes != tt.boxes {
+				t.Fatalf("grid is merged %v and in boxes %v, want %v and %v", merged, boxes, tt.merged, tt.boxes)
+			}
+			// hollow cube 54 outside and 6 inside, far cube 6, ring 24 outside, 8 inside and 2*8 top and bottom
+			if surface := g.Surface(cubes, false); surface != 60+6+48 {
+				t.Errorf("Surface() = %d, want %d", surface, 60+6+48)
+			}
+			g.FillOutside()
+			if surface := g.Surface(cubes, true); surface != 54+6+48 {
+				t.Errorf("Surface() outside = %d, want %d", surface, 54+6+48)
+			}
+			if v := g.At(Cube{1, 1, 1}); v != Air {
+				t.Errorf("middle of the hollow cube is %d, want Air", v)
+			}
+			if v := g.At(Cube{11, 1, 1}); v != Outside {
+				t.Errorf("middle of the ring is %d, want Outside", v)
+			}
+			if v := g.At(Cube{500, 500, 500}); v != Outside {
+				t.Errorf("far away is %d, want Outside", v)
+			}
+		})
+	}
+}
+
+// a box of cubes with an air pocket inside
+func hollow_box(lo Cube, size int) []Cube {
+	var cubes []Cube
+	for x := 0; x < size; x++ {
+		for y := 0; y < size; y++ {
+			for z := 0; z < size; z++ {
+				if x == 0 || y == 0 || z == 0 || x == size-1 || y == size-1 || z == size-1 {
+					cubes = append(cubes, Cube{lo[0] + x, lo[1] + y, lo[2] + z})
+				}
+			}
+		}
+	}
+	return cubes
+}
+
+func Test_pockets(t *testing.T) {
+	cubes := append(hollow_box(Cube{0, 0, 0}, 3), hollow_box(Cube{10, 0, 0}, 4)...)
+	cubes = append(cubes, hollow_box(Cube{-5, 50, 100}, 4)...)
+	want := []AirPocket{
+		{Volume: 8, Min: Cube{-4, 51, 101}, Max: Cube{-3, 52, 102}, Surface: 24},
+		{Volume: 8, Min: Cube{11, 1, 1}, Max: Cube{12, 2, 2}, Surface: 24},
+		{Volume: 1, Min: Cube{1, 1, 1}, Max: Cube{1, 1, 1}, Surface: 6},
+	}
+	for _, dense_cells := range []int{max_dense, 10000, 100} {
+		g := new_grid(cubes, dense_cells)
+		pockets := g.Pockets(cubes)
+		if len(pockets) != len(want) {
+			t.Fatalf("Pockets() with %d cells = %+v, want %+v", dense_cells, pockets, want)
+		}
+		for i := range want {
+			if pockets[i] != want[i] {
+				t.Errorf("Pockets() with %d cells, pocket %d = %+v, want %+v", dense_cells, i, pockets[i], want[i])
+			}
+		}
+		if all, outside := g.Surface(cubes, false), g.Surface(cubes, true); all-outside != 24+24+6 {
+			t.Errorf("surface %d - outside surface %d, want %d", all, outside, 24+24+6)
+		}
+	}
+}
+
+// cubes spread over a space far too big for cells, the time taken depends on the number of cubes
+func Test_spread(t *testing.T) {
+	r := rand.New(rand.NewSource(18))
+	var cubes []Cube
+	for i := 0; i < 600; i++ {
+		cubes = append(cubes, Cube{r.Intn(1_000_000), r.Intn(1_000_000), r.Intn(1_000_000)})
+	}
+	cubes = append(cubes, hollow_box(Cube{-400_000, 7, 900_000}, 4)...)
+	cubes = append(cubes, hollow_box(Cube{123_456, 654_321, 3}, 3)...)
+	want := []AirPocket{
+		{Volume: 8, Min: Cube{-399_999, 8, 900_001}, Max: Cube{-399_998, 9, 900_002}, Surface: 24},
+		{Volume: 1, Min: Cube{123_457, 654_322, 4}, Max: Cube{123_457, 654_322, 4}, Surface: 6},
+	}
+	start := time.Now()
+	g := NewGrid(cubes)
+	if g.boxes == nil {
+		t.Fatalf("grid is not in boxes")
+	}
+	if surface, want := g.Surface(cubes, false), NewSet(cubes).Surface(); surface != want {
+		t.Errorf("Surface() = %d, want %d", surface, want)
+	}
+	pockets := g.Pockets(cubes)
+	if len(pockets) != len(want) || pockets[0] != want[0] || pockets[1] != want[1] {
+		t.Errorf("Pockets() = %+v, want %+v", pockets, want)
+	}
+	if surface, want := g.Surface(cubes, true), NewSet(cubes).Surface()-24-6; surface != want {
+		t.Errorf("Surface() outside = %d, want %d", surface, want)
+	}
+	if took := time.Since(start); took > 2*time.Second {
+		t.Errorf("took %v, want at most 2s", took)
+	}
+}
+
+func Test_rules(t *testing.T) {
+	// the air in the middle of the box can only get out diagonally, between two cubes that share an edge
+	var cubes []Cube
+	for _, c := range hollow_box(Cube{0, 0, 0}, 3) {
+		if c != (Cube{1, 0, 0}) {
+			cubes = append(cubes, c)
+		}
+	}
+	cubes = append(cubes, Cube{100, -200, 1})
+	tests := []struct {
+		rules   Rules
+		escapes bool
+	}{
+		{rules: Rules{Air: 6, Solid: 6}},
+		{rules: Rules{Air: 6, Solid: 26}},
+		{rules: Rules{Air: 18, Solid: 6}, escapes: true},
+		{rules: Rules{Air: 18, Solid: 18}},
+		{rules: Rules{Air: 26, Solid: 6}, escapes: true},
+		{rules: Rules{Air: 26, Solid: 18}},
+		{rules: Rules{Air: 26, Solid: 26}},
+	}
+	for _, tt := range tests {
+		for _, dense_cells := range []int{max_dense, 10000, 100} {
+			g := new_grid(cubes, dense_cells)
+			if err := g.SetRules(tt.rules); err != nil {
+				t.Fatal(err)
+			}
+			pockets, surface := 1, 56+6
+			if tt.escapes {
+				pockets, surface = 0, 62+6
+			}
+			if n := len(g.Pockets(cubes)); n != pockets {
+				t.Errorf("%+v with %d cells: %d pockets, want %d", tt.rules, dense_cells, n, pockets)
+			}
+			if s := g.Surface(cubes, true); s != surface {
+				t.Errorf("%+v with %d cells: outside surface %d, want %d", tt.rules, dense_cells, s, surface)
+			}
+		}
+	}
+	if err := NewGrid(cubes).SetRules(Rules{Air: 8, Solid: 26}); err == nil {
+		t.Errorf("SetRules() with 8 neighbours did not fail")
+	}
+}